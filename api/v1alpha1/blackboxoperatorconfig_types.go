@@ -0,0 +1,275 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// HostMapping rewrites the probed host for a port.
+type HostMapping struct {
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port           int32  `json:"port,omitempty"`
+	ReplacePattern string `json:"replacePattern"`
+	// ReplaceWith may use capture groups of ReplacePattern like $1.
+	ReplaceWith string `json:"replaceWith"`
+	// +kubebuilder:validation:Enum=http;https
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	TargetPort int32 `json:"targetPort,omitempty"`
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// ModuleMapping selects the blackbox module for hosts matching a pattern on a port.
+type ModuleMapping struct {
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port          int32  `json:"port,omitempty"`
+	MatchPattern  string `json:"matchPattern"`
+	ReplaceModule string `json:"replaceModule"`
+}
+
+// ModuleRule selects a module for targets matching all of its conditions.
+// Rules are evaluated in order, the first match wins.
+type ModuleRule struct {
+	// Name is recorded as label module_rule on the targets.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinLength=1
+	Module string `json:"module"`
+	// +optional
+	HostPattern string `json:"hostPattern,omitempty"`
+	// Ports are port numbers or ranges like 8000-8999.
+	// +optional
+	Ports []string `json:"ports,omitempty"`
+	// +optional
+	PortNames []string `json:"portNames,omitempty"`
+	// +optional
+	Protocols []string `json:"protocols,omitempty"`
+	// +kubebuilder:validation:Enum=MESH_EXTERNAL;MESH_INTERNAL
+	// +optional
+	Location string `json:"location,omitempty"`
+	// +kubebuilder:validation:Enum=NONE;STATIC;DNS;DNS_ROUND_ROBIN;DYNAMIC_DNS
+	// +optional
+	Resolution string `json:"resolution,omitempty"`
+	// Selector matches the labels of the ServiceEntry.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ProberSpec describes how to reach the blackbox exporter, used by the probe and scrapeConfig outputs.
+type ProberSpec struct {
+	URL string `json:"url"`
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// AlertsSpec generates a PrometheusRule with alerts for the targets of every probed object.
+type AlertsSpec struct {
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Severity of the alerts unless a probed object or a template sets one, warning by default.
+	// +optional
+	Severity string `json:"severity,omitempty"`
+	// SeverityLabel is a label of the probed object that sets the severity of its alerts.
+	// +optional
+	SeverityLabel string `json:"severityLabel,omitempty"`
+	// CertExpiryThreshold is the remaining validity of a certificate below which the
+	// certificate expiry alert fires, 14d by default.
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	CertExpiryThreshold string `json:"certExpiryThreshold,omitempty"`
+	// RuleLabels are set on the PrometheusRules, e.g. to match the ruleSelector of Prometheus.
+	// +optional
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+	// Templates of the alerts, the ProbeFailed and CertificateExpiry alerts by default.
+	// +optional
+	Templates []AlertTemplate `json:"templates,omitempty"`
+}
+
+// AlertTemplate is an alerting rule generated for every probed object.
+type AlertTemplate struct {
+	// +kubebuilder:validation:MinLength=1
+	Alert string `json:"alert"`
+	// Expr is a Go template of the expression with the fields .Name, .Namespace, .Kind,
+	// .Selector, .HTTPSSelector and .CertExpiryThreshold.
+	// +kubebuilder:validation:MinLength=1
+	Expr string `json:"expr"`
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	For string `json:"for,omitempty"`
+	// HTTPSOnly generates the alert only for objects with https targets.
+	// +optional
+	HTTPSOnly bool `json:"httpsOnly,omitempty"`
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ExporterSpec declares the modules of the blackbox exporter. If set, the operator writes them
+// to the ConfigMap, reloads the exporter and only accepts declared modules.
+type ExporterSpec struct {
+	// +optional
+	ConfigMap ExporterConfigMap `json:"configMap,omitempty"`
+	// ReloadURL is the reload endpoint of the exporter, no reload if empty. With the DNS name of
+	// a Service every ready endpoint of the Service is reloaded.
+	// +optional
+	ReloadURL string `json:"reloadURL,omitempty"`
+	// ReloadDelay is the time between an update of the ConfigMap and the reload, 1m by default.
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	ReloadDelay string `json:"reloadDelay,omitempty"`
+	// +optional
+	Modules map[string]Module `json:"modules,omitempty"`
+}
+
+// ExporterConfigMap is the ConfigMap with the config file of the blackbox exporter.
+type ExporterConfigMap struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Key of the config file, blackbox.yaml by default.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// Module is a module of the blackbox exporter, the settings of the probers are passed as they are.
+type Module struct {
+	// +kubebuilder:validation:Enum=http;tcp;icmp;dns;grpc
+	Prober string `json:"prober"`
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	Timeout string `json:"timeout,omitempty"`
+	// +optional
+	HTTP *runtime.RawExtension `json:"http,omitempty"`
+	// +optional
+	TCP *runtime.RawExtension `json:"tcp,omitempty"`
+	// +optional
+	ICMP *runtime.RawExtension `json:"icmp,omitempty"`
+	// +optional
+	DNS *runtime.RawExtension `json:"dns,omitempty"`
+	// +optional
+	GRPC *runtime.RawExtension `json:"grpc,omitempty"`
+}
+
+// BlackboxOperatorConfigSpec mirrors the config file of the operator.
+// Fields that are not set get the same defaults as in the config file.
+type BlackboxOperatorConfigSpec struct {
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+	// +optional
+	DefaultModule string `json:"defaultModule,omitempty"`
+	// +optional
+	ServiceMonitorNamingPattern string `json:"serviceMonitorNamingPattern,omitempty"`
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	Interval string `json:"interval,omitempty"`
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// +optional
+	HostMappings []HostMapping `json:"hostMappings,omitempty"`
+	// +optional
+	ModuleMappings []ModuleMapping `json:"moduleMappings,omitempty"`
+	// ModuleRules are evaluated before ModuleMappings.
+	// +optional
+	ModuleRules []ModuleRule `json:"moduleRules,omitempty"`
+	// Selector of the blackbox exporter Service, used by the serviceMonitor output.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+	// Only ServiceEntries matching Include are probed, an empty selector includes all.
+	// +optional
+	Include metav1.LabelSelector `json:"include,omitempty"`
+	// ServiceEntries matching Exclude are not probed, an empty selector excludes none.
+	// +optional
+	Exclude metav1.LabelSelector `json:"exclude,omitempty"`
+	// Only ServiceEntries in namespaces matching NamespaceSelector are probed, an empty selector selects all.
+	// +optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Only ServiceEntries in these namespaces are probed if set.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// ServiceEntries in these namespaces are not probed.
+	// +optional
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// +optional
+	ProtocolModuleMappings map[string]string `json:"protocolModuleMappings,omitempty"`
+	// +kubebuilder:validation:Enum=serviceMonitor;probe;scrapeConfig
+	// +optional
+	Output string `json:"output,omitempty"`
+	// +optional
+	Prober *ProberSpec `json:"prober,omitempty"`
+	// +optional
+	Alerts *AlertsSpec `json:"alerts,omitempty"`
+	// +optional
+	Exporter *ExporterSpec `json:"exporter,omitempty"`
+}
+
+// BlackboxOperatorConfigStatus defines the observed state of BlackboxOperatorConfig.
+type BlackboxOperatorConfigStatus struct {
+	// ObservedGeneration is the generation of the spec that was validated last.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions of the config. The Valid condition reports validation errors.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ServiceEntries the config applies to, as namespace/name.
+	// +optional
+	ServiceEntries []string `json:"serviceEntries,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Valid",type=string,JSONPath=`.status.conditions[?(@.type=="Valid")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// BlackboxOperatorConfig is the Schema for the blackboxoperatorconfigs API.
+// It replaces the config file of the operator while it exists.
+type BlackboxOperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BlackboxOperatorConfigSpec   `json:"spec,omitempty"`
+	Status BlackboxOperatorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BlackboxOperatorConfigList contains a list of BlackboxOperatorConfig.
+type BlackboxOperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BlackboxOperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BlackboxOperatorConfig{}, &BlackboxOperatorConfigList{})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProbePolicySpec overrides the global config for ServiceEntries in the namespace of the policy.
+// Fields that are not set keep the value of the global config.
+type ProbePolicySpec struct {
+	// Selector of the ServiceEntries the policy applies to, an empty selector selects all
+	// ServiceEntries of the namespace.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+	// Priority decides the precedence if several policies select a ServiceEntry: higher priorities
+	// win, on equal priority the policy whose name sorts first wins.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// +optional
+	DefaultModule string `json:"defaultModule,omitempty"`
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	Interval string `json:"interval,omitempty"`
+	// +kubebuilder:validation:Pattern="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// +optional
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// HostMappings are evaluated before the ones of the global config.
+	// +optional
+	HostMappings []HostMapping `json:"hostMappings,omitempty"`
+	// ModuleMappings are evaluated before the ones of the global config.
+	// +optional
+	ModuleMappings []ModuleMapping `json:"moduleMappings,omitempty"`
+	// ProtocolModuleMappings are merged into the ones of the global config.
+	// +optional
+	ProtocolModuleMappings map[string]string `json:"protocolModuleMappings,omitempty"`
+}
+
+// ProbePolicyStatus defines the observed state of ProbePolicy.
+type ProbePolicyStatus struct {
+	// ObservedGeneration is the generation of the spec that was validated last.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions of the policy. The Valid condition reports validation errors, an invalid policy is not applied.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ServiceEntries the policy applies to.
+	// +optional
+	ServiceEntries []ProbePolicyServiceEntry `json:"serviceEntries,omitempty"`
+}
+
+// ProbePolicyServiceEntry is a ServiceEntry a policy applies to.
+type ProbePolicyServiceEntry struct {
+	Name string `json:"name"`
+	// Policies applied to the ServiceEntry by precedence, highest first.
+	Policies []string `json:"policies"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`
+// +kubebuilder:printcolumn:name="Valid",type=string,JSONPath=`.status.conditions[?(@.type=="Valid")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ProbePolicy is the Schema for the probepolicies API.
+// It overrides the global config for the ServiceEntries of its namespace.
+type ProbePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProbePolicySpec   `json:"spec,omitempty"`
+	Status ProbePolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProbePolicyList contains a list of ProbePolicy.
+type ProbePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProbePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProbePolicy{}, &ProbePolicyList{})
+}
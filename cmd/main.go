@@ -18,24 +18,42 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"flag"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
 	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"github.com/schmiddim/blackbox-operator/pkg/reachability"
+	"io/fs"
 	"os"
+	"time"
 
+	istioNetworkingv1 "istio.io/client-go/pkg/apis/networking/v1"
 	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istioNetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
 	"github.com/schmiddim/blackbox-operator/internal/controller"
 	// +kubebuilder:scaffold:imports
 )
@@ -43,7 +61,12 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	_        = istioNetworking.AddToScheme(scheme)
+	_        = istioNetworkingv1beta1.AddToScheme(scheme)
+	_        = istioNetworkingv1.AddToScheme(scheme)
 	_        = monitoringv1.AddToScheme(scheme)
+	_        = monitoringv1alpha1.AddToScheme(scheme)
+	_        = blackboxv1alpha1.AddToScheme(scheme)
+	_        = gatewayv1.AddToScheme(scheme)
 	setupLog = ctrl.Log.WithName("setup")
 )
 
@@ -54,12 +77,26 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(runRender(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var configFile string
+	var sweepInterval time.Duration
+	var sweepDryRun bool
+	var watchConfig bool
+	var externalNameServices bool
+	var ingresses bool
+	var httpRoutes bool
+	var prometheusURL string
+	var reachabilityInterval time.Duration
+	var configResourceName string
+	var exporterNamespace string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -72,6 +109,27 @@ func main() {
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&configFile, "config", "config.yaml", "Path to the configuration file")
+	flag.BoolVar(&watchConfig, "watch-config", true,
+		"If set, the configuration file is reloaded when it changes.")
+	flag.StringVar(&configResourceName, "config-resource", "default",
+		"Name of the BlackboxOperatorConfig resource. While it exists it is used instead of the configuration file.")
+	flag.StringVar(&exporterNamespace, "exporter-namespace", "monitoring",
+		"Namespace of the ConfigMap of the blackbox exporter. ConfigMaps are only read and written in this namespace. "+
+			"The Role granting access to them has to be in the same namespace, set EXPORTER_NAMESPACE of the manager Deployment to change both.")
+	flag.DurationVar(&sweepInterval, "sweep-interval", time.Hour,
+		"Interval for deleting orphaned generated objects. Orphans are always swept on startup, 0 disables the periodic sweep.")
+	flag.BoolVar(&sweepDryRun, "sweep-dry-run", false,
+		"If set, orphaned generated objects are only reported instead of deleted.")
+	flag.BoolVar(&externalNameServices, "external-name-services", false,
+		"If set, Services of type ExternalName are probed like ServiceEntries.")
+	flag.BoolVar(&ingresses, "ingresses", false,
+		"If set, the hosts of Ingresses are probed like ServiceEntries.")
+	flag.BoolVar(&httpRoutes, "http-routes", false,
+		"If set, the hostnames of Gateway API HTTPRoutes are probed like ServiceEntries.")
+	flag.StringVar(&prometheusURL, "prometheus-url", "",
+		"URL of the Prometheus HTTP API queried for the probe results of ServiceEntries. Empty disables the reachability annotations.")
+	flag.DurationVar(&reachabilityInterval, "reachability-interval", time.Minute,
+		"Interval for querying the probe results of ServiceEntries.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -141,6 +199,16 @@ func main() {
 		// if you are doing or is intended to do any operation such as perform cleanups
 		// after the manager stops then its usage might be unsafe.
 		// LeaderElectionReleaseOnCancel: true,
+
+		// only the ConfigMap of the blackbox exporter is read, it is labeled as managed
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {
+				Namespaces: map[string]cache.Config{exporterNamespace: {}},
+				Label:      labels.SelectorFromSet(labels.Set{monitoring.ManagedByLabel: monitoring.ManagedByValue}),
+			},
+			// the endpoints of the exporter are reloaded
+			&discoveryv1.EndpointSlice{}: {Namespaces: map[string]cache.Config{exporterNamespace: {}}},
+		}},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -148,19 +216,182 @@ func main() {
 	}
 
 	cfg, err := config.LoadConfig(configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the BlackboxOperatorConfig resource may provide the config instead
+		setupLog.Info("config file not found, using defaults", "path", configFile)
+		cfg, err = config.ParseConfig(nil)
+	}
 	if err != nil {
 		setupLog.Error(err, "unable to load config")
 		os.Exit(1)
 	}
 
+	serviceEntryAPI, err := controller.DetectServiceEntryAPI(mgr.GetRESTMapper())
+	if err != nil {
+		setupLog.Error(err, "unable to detect the ServiceEntry API")
+		os.Exit(1)
+	}
+	setupLog.Info("using ServiceEntry API", "version", serviceEntryAPI.Version)
+	// DestinationRules are optional, without them no port is probed with TLS origination
+	destinationRuleAPI, err := controller.DetectDestinationRuleAPI(mgr.GetRESTMapper())
+	switch {
+	case meta.IsNoMatchError(err):
+		setupLog.Info("DestinationRule API not served, TLS origination is not detected")
+	case err != nil:
+		setupLog.Error(err, "unable to detect the DestinationRule API")
+		os.Exit(1)
+	default:
+		setupLog.Info("using DestinationRule API", "version", destinationRuleAPI.Version)
+	}
+
+	configStore := config.NewStore(cfg)
+	configChanged := make(chan event.GenericEvent, 1)
+	// every reconciler of Sources and the ones of the exporter config and the ProbePolicies need
+	// their own channel
+	configChangedFor := controller.FanOut(configChanged, 6)
+
 	if err = (&controller.ServiceEntryReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: cfg,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Config:             configStore,
+		ConfigChanged:      configChangedFor[0],
+		Recorder:           mgr.GetEventRecorder("blackbox-operator"),
+		ServiceEntryAPI:    serviceEntryAPI,
+		DestinationRuleAPI: destinationRuleAPI,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ServiceEntry")
 		os.Exit(1)
 	}
+	if externalNameServices {
+		if err = (&controller.ExternalNameServiceReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			Config:        configStore,
+			ConfigChanged: configChangedFor[1],
+			Recorder:      mgr.GetEventRecorder("blackbox-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ExternalNameService")
+			os.Exit(1)
+		}
+	}
+	if ingresses {
+		if err = (&controller.IngressReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			Config:        configStore,
+			ConfigChanged: configChangedFor[2],
+			Recorder:      mgr.GetEventRecorder("blackbox-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+			os.Exit(1)
+		}
+	}
+	if httpRoutes {
+		if err = (&controller.HTTPRouteReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			Config:        configStore,
+			ConfigChanged: configChangedFor[3],
+			Recorder:      mgr.GetEventRecorder("blackbox-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "HTTPRoute")
+			os.Exit(1)
+		}
+	}
+	if err = (&controller.ExporterConfigReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Config:        configStore,
+		Reader:        mgr.GetAPIReader(),
+		Namespace:     exporterNamespace,
+		ConfigChanged: configChangedFor[4],
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ExporterConfig")
+		os.Exit(1)
+	}
+	if err = (&controller.BlackboxOperatorConfigReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Store:           configStore,
+		Name:            configResourceName,
+		Changed:         configChanged,
+		ServiceEntryAPI: serviceEntryAPI,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BlackboxOperatorConfig")
+		os.Exit(1)
+	}
+	if err = (&controller.ProbePolicyReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Config:          configStore,
+		ConfigChanged:   configChangedFor[5],
+		ServiceEntryAPI: serviceEntryAPI,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProbePolicy")
+		os.Exit(1)
+	}
+	if watchConfig {
+		reloader := &controller.ConfigReloader{
+			Path:     configFile,
+			Store:    configStore,
+			Changed:  configChanged,
+			Recorder: mgr.GetEventRecorder("blackbox-operator"),
+		}
+		// Events about the config are recorded on the operator Pod, its name is set via the downward API
+		if podName, podNamespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE"); podName != "" && podNamespace != "" {
+			reloader.Regarding = &corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       podName,
+				Namespace:  podNamespace,
+			}
+		}
+		if err = mgr.Add(reloader); err != nil {
+			setupLog.Error(err, "unable to set up config reloader")
+			os.Exit(1)
+		}
+	}
+	if err = (&controller.ServiceMonitorAdopter{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		ServiceEntryAPI: serviceEntryAPI,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up ServiceMonitor adoption")
+		os.Exit(1)
+	}
+	if err = (&controller.ServiceMonitorSweeper{
+		Client:          mgr.GetClient(),
+		Interval:        sweepInterval,
+		DryRun:          sweepDryRun,
+		ServiceEntryAPI: serviceEntryAPI,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up ServiceMonitor sweeper")
+		os.Exit(1)
+	}
+	if prometheusURL != "" {
+		querier, err := reachability.NewPrometheusQuerier(prometheusURL)
+		if err != nil {
+			setupLog.Error(err, "unable to create Prometheus client", "url", prometheusURL)
+			os.Exit(1)
+		}
+		if err = (&controller.ReachabilityChecker{
+			Client:          mgr.GetClient(),
+			Querier:         querier,
+			Interval:        reachabilityInterval,
+			ServiceEntryAPI: serviceEntryAPI,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to set up reachability checker")
+			os.Exit(1)
+		}
+	}
+	// generated objects and their targets are counted from the cache on every scrape
+	if err = metrics.Registry.Register(&controller.OutputCollector{
+		Reader:  mgr.GetClient(),
+		Timeout: 10 * time.Second,
+	}); err != nil {
+		setupLog.Error(err, "unable to register metrics of generated objects")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/manifest"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const renderUsage = `Usage: blackbox-operator render [flags] SERVICE_ENTRY_FILE...
+
+Prints the objects the operator generates for the ServiceEntries, ExternalName Services, Ingresses and
+HTTPRoutes in the given files. DestinationRules in the files are used for TLS origination, Gateways for
+the listeners of HTTPRoutes and the labels of Namespaces for the namespaceSelector of the config. Objects
+in namespaces that are not selected are skipped. Other kinds are rejected.
+With --diff the exit code is 1 if there are differences. Only the rendered objects are compared, existing
+objects the operator would delete, e.g. of excluded objects or with a previous name, are not reported.
+
+Flags:
+`
+
+// existingFunc returns the existing object for the desired one, nil if there is none.
+type existingFunc func(desired *unstructured.Unstructured) (*unstructured.Unstructured, error)
+
+// runRender implements the render subcommand and returns the exit code.
+func runRender(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, renderUsage)
+		flags.PrintDefaults()
+	}
+	var configFile, output, diff string
+	flags.StringVar(&configFile, "config", "config.yaml", "Path to the configuration file")
+	flags.StringVar(&output, "output", "yaml", "Output format, yaml or json")
+	flags.StringVar(&diff, "diff", "",
+		"Show a diff against the existing objects instead of printing them. "+
+			"Either a path to a file with the existing objects or 'live' to fetch them from the cluster.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (output != "yaml" && output != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	rendered, err := render(cfg, flags.Args(), stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if diff == "" {
+		if err := printObjects(stdout, rendered, output); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	var existing existingFunc
+	if diff == "live" {
+		existing, err = liveObjects()
+	} else {
+		existing, err = fileObjects(diff)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	changed, err := diffObjects(stdout, rendered, existing, output)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if changed {
+		return 1
+	}
+	return 0
+}
+
+// render maps the ServiceEntries, ExternalName Services, Ingresses and HTTPRoutes of the files like
+// the reconcilers do. DestinationRules in the files are used for TLS origination, Gateways for
+// HTTPRoutes and Namespaces for the namespace selection, ProbePolicies are not applied.
+func render(cfg *config.Config, files []string, stderr io.Writer) ([]*unstructured.Unstructured, error) {
+	log := logr.Discard()
+	mapper := monitoring.NewMapper(cfg, &log)
+	exclude := monitoring.NewExcluded(cfg)
+	namespaces := monitoring.NewNamespaceFilter(cfg)
+
+	var serviceEntries []*istioNetworking.ServiceEntry
+	var services []*corev1.Service
+	var ingresses []*networkingv1.Ingress
+	var httpRoutes []*gatewayv1.HTTPRoute
+	var gateways []*gatewayv1.Gateway
+	var destinationRules []*istioNetworking.DestinationRule
+	// like for the operator, a namespace that is not found has no labels
+	namespaceLabels := map[string]map[string]string{}
+	for _, file := range files {
+		docs, err := manifest.LoadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var typeMeta metav1.TypeMeta
+			if err := json.Unmarshal(doc, &typeMeta); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			var obj interface{}
+			switch typeMeta.Kind {
+			case "ServiceEntry":
+				se := &istioNetworking.ServiceEntry{}
+				serviceEntries, obj = append(serviceEntries, se), se
+			case "DestinationRule":
+				dr := &istioNetworking.DestinationRule{}
+				destinationRules, obj = append(destinationRules, dr), dr
+			case "Service":
+				svc := &corev1.Service{}
+				services, obj = append(services, svc), svc
+			case "Ingress":
+				ing := &networkingv1.Ingress{}
+				ingresses, obj = append(ingresses, ing), ing
+			case "HTTPRoute":
+				route := &gatewayv1.HTTPRoute{}
+				httpRoutes, obj = append(httpRoutes, route), route
+			case "Gateway":
+				gateway := &gatewayv1.Gateway{}
+				gateways, obj = append(gateways, gateway), gateway
+			case "Namespace":
+				obj = &corev1.Namespace{}
+			default:
+				return nil, fmt.Errorf("%s: unsupported kind %q", file, typeMeta.Kind)
+			}
+			if err := json.Unmarshal(doc, obj); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				namespaceLabels[ns.Name] = ns.Labels
+			}
+		}
+	}
+
+	var sources []*monitoring.Source
+	tlsPorts := map[*monitoring.Source]monitoring.TLSPorts{}
+	for _, se := range serviceEntries {
+		src := monitoring.FromServiceEntry(se)
+		sources, tlsPorts[src] = append(sources, src), monitoring.TLSOrigination(se, destinationRules)
+	}
+	for _, svc := range services {
+		if !monitoring.IsExternalName(svc) {
+			fmt.Fprintf(stderr, "%s/%s: not an ExternalName Service\n", svc.Namespace, svc.Name)
+			continue
+		}
+		sources = append(sources, monitoring.FromService(svc))
+	}
+	for _, ing := range ingresses {
+		sources = append(sources, monitoring.FromIngress(ing))
+	}
+	for _, route := range httpRoutes {
+		sources = append(sources, monitoring.FromHTTPRoute(route, gateways))
+	}
+
+	var rendered []*unstructured.Unstructured
+	for _, src := range sources {
+		if !namespaces.IsSelected(src.Namespace, namespaceLabels[src.Namespace]) {
+			fmt.Fprintf(stderr, "%s/%s: namespace not selected\n", src.Namespace, src.Name)
+			continue
+		}
+		if exclude.IsExcluded(src.Labels) {
+			fmt.Fprintf(stderr, "%s/%s: excluded\n", src.Namespace, src.Name)
+			continue
+		}
+		targets := monitoring.GenerateTargets(cfg, &log, src, tlsPorts[src])
+		for _, invalid := range monitoring.Summarize(targets).InvalidAnnotations {
+			fmt.Fprintf(stderr, "%s/%s: ignoring %s\n", src.Namespace, src.Name, invalid)
+		}
+		obj, err := mapper.Map(src, targets)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", src.Namespace, src.Name, err)
+		}
+		if obj == nil {
+			fmt.Fprintf(stderr, "%s/%s: every target skipped\n", src.Namespace, src.Name)
+			continue
+		}
+		u, err := normalize(obj)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, u)
+
+		rule, err := monitoring.MapAlerts(cfg, src, targets)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", src.Namespace, src.Name, err)
+		}
+		if rule == nil {
+			continue
+		}
+		if u, err = normalize(rule); err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, u)
+	}
+	return rendered, nil
+}
+
+// normalize converts obj to the fields managed by the operator: apiVersion, kind, name,
+// namespace, labels and spec. Fields set by the API server are dropped, so existing objects
+// can be compared with rendered ones.
+func normalize(obj runtime.Object) (*unstructured.Unstructured, error) {
+	var content map[string]interface{}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content = u.Object
+	} else {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return nil, err
+		}
+		content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		content["apiVersion"], content["kind"] = gvk.GroupVersion().String(), gvk.Kind
+	}
+	src := &unstructured.Unstructured{Object: content}
+	normalized := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	normalized.SetAPIVersion(src.GetAPIVersion())
+	normalized.SetKind(src.GetKind())
+	normalized.SetName(src.GetName())
+	normalized.SetNamespace(src.GetNamespace())
+	normalized.SetLabels(src.GetLabels())
+	if spec, ok := content["spec"]; ok {
+		normalized.Object["spec"] = spec
+	}
+	return normalized, nil
+}
+
+func printObjects(w io.Writer, objects []*unstructured.Unstructured, output string) error {
+	if output == "json" {
+		var v interface{}
+		if len(objects) == 1 {
+			v = objects[0].Object
+		} else {
+			list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "List"}}
+			for _, obj := range objects {
+				list.Items = append(list.Items, *obj)
+			}
+			v = list.UnstructuredContent()
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	for i, obj := range objects {
+		data, err := marshal(obj, output)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		if _, err := fmt.Fprint(w, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffObjects writes a unified diff between the existing and the rendered objects and
+// reports whether they differ.
+func diffObjects(w io.Writer, rendered []*unstructured.Unstructured, existing existingFunc, output string) (bool, error) {
+	changed := false
+	for _, desired := range rendered {
+		current, err := existing(desired)
+		if err != nil {
+			return false, err
+		}
+		var currentData string
+		if current != nil {
+			normalized, err := normalize(current)
+			if err != nil {
+				return false, err
+			}
+			if currentData, err = marshal(normalized, output); err != nil {
+				return false, err
+			}
+		}
+		desiredData, err := marshal(desired, output)
+		if err != nil {
+			return false, err
+		}
+		name := fmt.Sprintf("%s %s/%s", desired.GetKind(), desired.GetNamespace(), desired.GetName())
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(currentData),
+			B:        difflib.SplitLines(desiredData),
+			FromFile: "existing " + name,
+			ToFile:   "rendered " + name,
+			Context:  3,
+		})
+		if err != nil {
+			return false, err
+		}
+		if text != "" {
+			changed = true
+			fmt.Fprint(w, text)
+		}
+	}
+	return changed, nil
+}
+
+func marshal(obj *unstructured.Unstructured, output string) (string, error) {
+	if output == "json" {
+		data, err := json.MarshalIndent(obj.Object, "", "  ")
+		return string(data) + "\n", err
+	}
+	data, err := yaml.Marshal(obj.Object)
+	return string(data), err
+}
+
+// fileObjects returns the objects of a file as existing objects, matched by kind, namespace and name.
+func fileObjects(filename string) (existingFunc, error) {
+	docs, err := manifest.LoadAll(filename)
+	if err != nil {
+		return nil, err
+	}
+	objects := map[string]*unstructured.Unstructured{}
+	for _, doc := range docs {
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		objects[objectKey(u)] = u
+	}
+	return func(desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return objects[objectKey(desired)], nil
+	}, nil
+}
+
+// liveObjects returns the objects from the cluster of the current kubeconfig as existing objects.
+func liveObjects() (existingFunc, error) {
+	restConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	return func(desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(desired.GroupVersionKind())
+		err := c.Get(context.Background(), client.ObjectKeyFromObject(desired), current)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		if err != nil {
+			return nil, nil
+		}
+		return current, nil
+	}, nil
+}
+
+func objectKey(u *unstructured.Unstructured) string {
+	return strings.Join([]string{u.GetKind(), u.GetNamespace(), u.GetName()}, "/")
+}
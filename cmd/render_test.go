@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	expected, err := os.ReadFile("testdata/service-monitor.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := runRender([]string{"--config", "testdata/config.yaml", "testdata/service-entry.yaml"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != string(expected) {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+}
+
+func TestRenderRoutes(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runRender([]string{"--config", "testdata/config.yaml", "testdata/routes.yaml"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"name: sm-web-ingress", "https://shop.example.com:443/cart",
+		"name: sm-web-httproute", "https://api.example.com:443",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRenderAlerts(t *testing.T) {
+	cfg, err := os.ReadFile("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerting := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(alerting, append(cfg, []byte("alerts:\n  enabled: true\n")...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := runRender([]string{"--config", alerting, "testdata/service-entry.yaml"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"kind: ServiceMonitor", "kind: PrometheusRule", "alert: ProbeFailed"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRenderUnsupportedKind(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "typo.yaml")
+	if err := os.WriteFile(file, []byte("apiVersion: networking.istio.io/v1\nkind: ServiceEntri\nmetadata:\n  name: typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := runRender([]string{"--config", "testdata/config.yaml", file}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), `unsupported kind "ServiceEntri"`) {
+		t.Errorf("expected exit code 1 for an unsupported kind, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRenderNamespaceSelector(t *testing.T) {
+	cfg, err := os.ReadFile("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	selecting := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(selecting, append(cfg, []byte("namespaceSelector:\n  matchLabels:\n    probe: \"true\"\n")...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	namespace := filepath.Join(t.TempDir(), "namespace.yaml")
+	if err := os.WriteFile(namespace, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: default\n  labels:\n    probe: \"true\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runRender([]string{"--config", selecting, "testdata/service-entry.yaml"}, &stdout, &stderr)
+	if code != 0 || stdout.Len() > 0 || !strings.Contains(stderr.String(), "namespace not selected") {
+		t.Errorf("expected nothing rendered without the labels of the namespace, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = runRender([]string{"--config", selecting, namespace, "testdata/service-entry.yaml"}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "kind: ServiceMonitor") {
+		t.Errorf("expected the ServiceMonitor in a selected namespace, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runRender([]string{"--config", "testdata/config.yaml", "--output", "json",
+		"testdata/service-entry.yaml", "testdata/service-entry.yaml"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var list struct {
+		Kind  string        `json:"kind"`
+		Items []interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &list); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if list.Kind != "List" || len(list.Items) != 2 {
+		t.Errorf("expected a List with 2 items, got %s with %d", list.Kind, len(list.Items))
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	existing, err := os.ReadFile("testdata/service-monitor.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(t.TempDir(), "changed.yaml")
+	if err := os.WriteFile(changed, bytes.Replace(existing, []byte("http_2xx"), []byte("tcp_connect"), 1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if err := os.WriteFile(missing, []byte("---\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		diff     string
+		wantCode int
+		want     []string
+	}{
+		{name: "unchanged", diff: "testdata/service-monitor.yaml", wantCode: 0},
+		{name: "changed", diff: changed, wantCode: 1, want: []string{"-      - tcp_connect", "+      - http_2xx"}},
+		{name: "missing", diff: missing, wantCode: 1, want: []string{"+kind: ServiceMonitor"}},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runRender([]string{"--config", "testdata/config.yaml", "--diff", tt.diff, "testdata/service-entry.yaml"}, &stdout, &stderr)
+		if code != tt.wantCode {
+			t.Errorf("%s: expected exit code %d, got %d: %s", tt.name, tt.wantCode, code, stderr.String())
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%s: expected diff to contain %q, got:\n%s", tt.name, want, stdout.String())
+			}
+		}
+		if tt.want == nil && stdout.Len() > 0 {
+			t.Errorf("%s: expected no diff, got:\n%s", tt.name, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const conditionTypeValid = "Valid"
+
+// BlackboxOperatorConfigReconciler applies the BlackboxOperatorConfig with the configured name.
+// While it exists and is valid it overrides the config file, an invalid spec keeps the
+// last valid config in effect and is reported in the status.
+type BlackboxOperatorConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Store  *config.Store
+	// Name of the BlackboxOperatorConfig that is used
+	Name string
+	// Changed receives an event after a new Config was applied, optional.
+	Changed chan<- event.GenericEvent
+	// ServiceEntryAPI is the served version of the ServiceEntry API, v1alpha3 if not set.
+	ServiceEntryAPI ServiceEntryAPI
+
+	// applied is the spec of the override in the Store, the compiled Config can't be compared.
+	applied *blackboxv1alpha1.BlackboxOperatorConfigSpec
+}
+
+// +kubebuilder:rbac:groups=blackbox.schmiddim.io,resources=blackboxoperatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=blackbox.schmiddim.io,resources=blackboxoperatorconfigs/status,verbs=get;update;patch
+
+func (r *BlackboxOperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var boc blackboxv1alpha1.BlackboxOperatorConfig
+	if err := r.Get(ctx, req.NamespacedName, &boc); err != nil {
+		if errors.IsNotFound(err) {
+			if r.Store.Override() != nil {
+				logger.Info("BlackboxOperatorConfig deleted, falling back to config file", "name", req.Name)
+				r.Store.SetOverride(nil)
+				r.applied = nil
+				r.notify()
+			}
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	status := boc.Status.DeepCopy()
+	status.ObservedGeneration = boc.Generation
+
+	cfg, err := ConfigFromSpec(&boc.Spec)
+	if err != nil {
+		logger.Info("BlackboxOperatorConfig rejected, keeping the last valid config", "name", boc.Name, "error", err.Error())
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               conditionTypeValid,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidConfig",
+			Message:            err.Error(),
+			ObservedGeneration: boc.Generation,
+		})
+		return ctrl.Result{}, r.updateStatus(ctx, &boc, status)
+	}
+
+	if r.applied == nil || !reflect.DeepEqual(*r.applied, boc.Spec) {
+		r.Store.SetOverride(cfg)
+		r.applied = boc.Spec.DeepCopy()
+		logger.Info("BlackboxOperatorConfig applied", "name", boc.Name)
+		r.notify()
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionTypeValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Applied",
+		Message:            "Config is valid and in effect",
+		ObservedGeneration: boc.Generation,
+	})
+
+	serviceEntries, err := r.appliedServiceEntries(ctx, cfg)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	status.ServiceEntries = serviceEntries
+
+	return ctrl.Result{}, r.updateStatus(ctx, &boc, status)
+}
+
+// LoadOverride applies the BlackboxOperatorConfig read by the reader, so Sources are not
+// reconciled against the config file on start while it overrides it. An invalid spec is
+// left to the reconcile, which reports it in the status.
+func (r *BlackboxOperatorConfigReconciler) LoadOverride(ctx context.Context, reader client.Reader) error {
+	var boc blackboxv1alpha1.BlackboxOperatorConfig
+	if err := reader.Get(ctx, client.ObjectKey{Name: r.Name}, &boc); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	cfg, err := ConfigFromSpec(&boc.Spec)
+	if err != nil {
+		return nil
+	}
+	r.Store.SetOverride(cfg)
+	r.applied = boc.Spec.DeepCopy()
+	return nil
+}
+
+// ConfigFromSpec converts the spec to a Config. The spec has the same format as the
+// config file, so it gets the same defaults and validation.
+func ConfigFromSpec(spec *blackboxv1alpha1.BlackboxOperatorConfigSpec) (*config.Config, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return config.ParseConfig(data)
+}
+
+// appliedServiceEntries returns all selected ServiceEntries as namespace/name.
+func (r *BlackboxOperatorConfigReconciler) appliedServiceEntries(ctx context.Context, cfg *config.Config) ([]string, error) {
+	serviceEntries, err := r.ServiceEntryAPI.List(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	exclude := monitoring.NewExcluded(cfg)
+	var names []string
+	for _, se := range serviceEntries {
+		if exclude.IsExcluded(se.Labels) {
+			continue
+		}
+		selected, err := isNamespaceSelected(ctx, r.Client, cfg, se.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !selected {
+			continue
+		}
+		names = append(names, se.Namespace+"/"+se.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (r *BlackboxOperatorConfigReconciler) updateStatus(ctx context.Context, boc *blackboxv1alpha1.BlackboxOperatorConfig, status *blackboxv1alpha1.BlackboxOperatorConfigStatus) error {
+	if reflect.DeepEqual(&boc.Status, status) {
+		return nil
+	}
+	patch := client.MergeFrom(boc.DeepCopy())
+	boc.Status = *status
+	return r.Status().Patch(ctx, boc, patch)
+}
+
+func (r *BlackboxOperatorConfigReconciler) notify() {
+	if r.Changed == nil {
+		return
+	}
+	select {
+	case r.Changed <- event.GenericEvent{Object: &metav1.PartialObjectMetadata{}}:
+	default:
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager and applies the current
+// BlackboxOperatorConfig. ServiceEntry changes are mapped to the config to keep its status up to date.
+// Without the CRD installed the controller is skipped and only the config file is used.
+func (r *BlackboxOperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	installed, err := isInstalled(mgr, &blackboxv1alpha1.BlackboxOperatorConfig{})
+	if err != nil {
+		return err
+	}
+	if !installed {
+		mgr.GetLogger().Info("BlackboxOperatorConfig CRD not installed, using the config file only")
+		return nil
+	}
+	// the cache is not started yet
+	if err := r.LoadOverride(context.Background(), mgr.GetAPIReader()); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&blackboxv1alpha1.BlackboxOperatorConfig{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetName() == r.Name
+		}))).
+		Watches(r.ServiceEntryAPI.NewObject(), handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+			return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: r.Name}}}
+		}), builder.WithPredicates(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				// only label changes affect the exclusion
+				return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+			},
+		})).
+		Complete(r)
+}
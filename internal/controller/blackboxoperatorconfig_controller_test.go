@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("BlackboxOperatorConfig Controller", func() {
+	Context("When reconciling a BlackboxOperatorConfig", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "default"}
+
+		var store *config.Store
+		var changed chan event.GenericEvent
+		var reconciler *BlackboxOperatorConfigReconciler
+
+		BeforeEach(func() {
+			fileConfig, err := config.ParseConfig([]byte(`defaultModule: http_2xx`))
+			Expect(err).NotTo(HaveOccurred())
+			store = config.NewStore(fileConfig)
+			changed = make(chan event.GenericEvent, 1)
+			reconciler = &BlackboxOperatorConfigReconciler{
+				Client:  k8sClient,
+				Scheme:  k8sClient.Scheme(),
+				Store:   store,
+				Name:    key.Name,
+				Changed: changed,
+			}
+		})
+
+		AfterEach(func() {
+			boc := &blackboxv1alpha1.BlackboxOperatorConfig{}
+			if err := k8sClient.Get(ctx, key, boc); err == nil {
+				Expect(k8sClient.Delete(ctx, boc)).To(Succeed())
+			}
+		})
+
+		It("should override the config file and fall back to it after deletion", func() {
+			boc := &blackboxv1alpha1.BlackboxOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+				Spec:       blackboxv1alpha1.BlackboxOperatorConfigSpec{DefaultModule: "tcp_connect"},
+			}
+			Expect(k8sClient.Create(ctx, boc)).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Get().DefaultModule).To(Equal("tcp_connect"))
+			Expect(changed).To(HaveLen(1))
+
+			Expect(k8sClient.Get(ctx, key, boc)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(boc.Status.Conditions, conditionTypeValid)).To(BeTrue())
+			Expect(boc.Status.ObservedGeneration).To(Equal(boc.Generation))
+
+			Expect(k8sClient.Delete(ctx, boc)).To(Succeed())
+			<-changed
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Get().DefaultModule).To(Equal("http_2xx"))
+			Expect(changed).To(HaveLen(1))
+		})
+
+		It("should apply the BlackboxOperatorConfig before the first reconcile", func() {
+			Expect(reconciler.LoadOverride(ctx, k8sClient)).To(Succeed())
+			Expect(store.Override()).To(BeNil())
+
+			boc := &blackboxv1alpha1.BlackboxOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+				Spec:       blackboxv1alpha1.BlackboxOperatorConfigSpec{DefaultModule: "tcp_connect"},
+			}
+			Expect(k8sClient.Create(ctx, boc)).To(Succeed())
+
+			Expect(reconciler.LoadOverride(ctx, k8sClient)).To(Succeed())
+			Expect(store.Get().DefaultModule).To(Equal("tcp_connect"))
+
+			// the reconcile does not announce the already applied config again
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeEmpty())
+		})
+
+		It("should report an invalid spec and keep the last valid config", func() {
+			boc := &blackboxv1alpha1.BlackboxOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+				Spec:       blackboxv1alpha1.BlackboxOperatorConfigSpec{ServiceMonitorNamingPattern: "sm-%"},
+			}
+			Expect(k8sClient.Create(ctx, boc)).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Get().DefaultModule).To(Equal("http_2xx"))
+			Expect(changed).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, key, boc)).To(Succeed())
+			condition := meta.FindStatusCondition(boc.Status.Conditions, conditionTypeValid)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("InvalidConfig"))
+		})
+	})
+})
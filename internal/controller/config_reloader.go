@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const reloadDebounce = 200 * time.Millisecond
+
+// ConfigReloader watches the config file and replaces the Config in the Store when it changes.
+// The directory of the file is watched instead of the file itself, so the symlink swap
+// used for mounted ConfigMaps is picked up as well. An invalid config is rejected and
+// the last valid Config stays in effect.
+type ConfigReloader struct {
+	Path  string
+	Store *config.Store
+	// Changed receives an event after a new Config was applied, optional.
+	Changed chan<- event.GenericEvent
+	// Recorder and Regarding are used to emit Events on reloads, optional.
+	Recorder  events.EventRecorder
+	Regarding runtime.Object
+
+	current []byte
+}
+
+// Start watches the config file until the context is cancelled.
+func (r *ConfigReloader) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("config-reloader")
+	ctx = log.IntoContext(ctx, logger)
+
+	if data, err := os.ReadFile(r.Path); err == nil {
+		r.current = data
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(r.Path)); err != nil {
+		return err
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			debounce = time.After(reloadDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error(err, "watching config file failed")
+		case <-debounce:
+			debounce = nil
+			_ = r.Reload(ctx)
+		}
+	}
+}
+
+// Reload reads the config file and applies it if its content changed.
+func (r *ConfigReloader) Reload(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		r.rejected(ctx, err)
+		return err
+	}
+	if bytes.Equal(data, r.current) {
+		return nil
+	}
+	cfg, err := config.ParseConfig(data)
+	if err != nil {
+		r.rejected(ctx, err)
+		return err
+	}
+
+	r.current = data
+	r.Store.Set(cfg)
+	configReloads.WithLabelValues("success").Inc()
+	configLastReloadSuccess.Set(1)
+	logger.Info("Config reloaded", "path", r.Path)
+	r.event(corev1.EventTypeNormal, "ConfigReloaded", "Config file %s reloaded", r.Path)
+
+	if r.Changed != nil {
+		select {
+		// the event is only a trigger, the object is never reconciled
+		case r.Changed <- event.GenericEvent{Object: &metav1.PartialObjectMetadata{}}:
+		default:
+			// a reconcile of all ServiceEntries is already pending
+		}
+	}
+	return nil
+}
+
+func (r *ConfigReloader) rejected(ctx context.Context, err error) {
+	configReloads.WithLabelValues("failure").Inc()
+	configLastReloadSuccess.Set(0)
+	log.FromContext(ctx).Error(err, "Config rejected, keeping the last valid config", "path", r.Path)
+	r.event(corev1.EventTypeWarning, "ConfigRejected", "Config file %s rejected: %v", r.Path, err)
+}
+
+func (r *ConfigReloader) event(eventType, reason, note string, args ...interface{}) {
+	if r.Recorder == nil || r.Regarding == nil {
+		return
+	}
+	r.Recorder.Eventf(r.Regarding, nil, eventType, reason, "Reload", note, args...)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica
+// keeps its config up to date, so a new leader starts with the current one.
+func (r *ConfigReloader) NeedLeaderElection() bool {
+	return false
+}
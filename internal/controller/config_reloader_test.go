@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("Config Reloader", func() {
+	Context("When the config file changes", func() {
+		ctx := context.Background()
+
+		var path string
+		var store *config.Store
+		var changed chan event.GenericEvent
+		var reloader *ConfigReloader
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+			Expect(os.WriteFile(path, []byte(`defaultModule: http_2xx`), 0o600)).To(Succeed())
+			cfg, err := config.LoadConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			store = config.NewStore(cfg)
+			changed = make(chan event.GenericEvent, 1)
+			reloader = &ConfigReloader{Path: path, Store: store, Changed: changed}
+		})
+
+		It("should apply a valid config and trigger a reconcile", func() {
+			Expect(os.WriteFile(path, []byte(`defaultModule: tcp_connect`), 0o600)).To(Succeed())
+			Expect(reloader.Reload(ctx)).To(Succeed())
+			Expect(store.Get().DefaultModule).To(Equal("tcp_connect"))
+			Expect(changed).To(HaveLen(1))
+		})
+
+		It("should keep the last valid config when the new one is invalid", func() {
+			Expect(os.WriteFile(path, []byte(`serviceMonitorNamingPattern: "sm-%"`), 0o600)).To(Succeed())
+			Expect(reloader.Reload(ctx)).NotTo(Succeed())
+			Expect(store.Get().DefaultModule).To(Equal("http_2xx"))
+			Expect(changed).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	istioNetworkingv1 "istio.io/client-go/pkg/apis/networking/v1"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istioNetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DestinationRuleAPI reads DestinationRules in one version of the networking.istio.io API. Like
+// ServiceEntries they are converted to the v1alpha3 types used by the mappers.
+// The zero value uses v1alpha3.
+type DestinationRuleAPI struct {
+	Version string
+}
+
+// DetectDestinationRuleAPI returns the most recent served version of the DestinationRule API.
+// The error is a NoMatch error if none is served.
+func DetectDestinationRuleAPI(mapper meta.RESTMapper) (DestinationRuleAPI, error) {
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: istioNetworking.GroupName, Kind: "DestinationRule"}, serviceEntryVersions...)
+	if err != nil {
+		return DestinationRuleAPI{}, fmt.Errorf("no supported DestinationRule API version served: %w", err)
+	}
+	return DestinationRuleAPI{Version: mapping.GroupVersionKind.Version}, nil
+}
+
+// NewObject returns an empty DestinationRule of the version, used to set up watches.
+func (a DestinationRuleAPI) NewObject() client.Object {
+	switch a.Version {
+	case "v1":
+		return &istioNetworkingv1.DestinationRule{}
+	case "v1beta1":
+		return &istioNetworkingv1beta1.DestinationRule{}
+	default:
+		return &istioNetworking.DestinationRule{}
+	}
+}
+
+// List lists DestinationRules.
+func (a DestinationRuleAPI) List(ctx context.Context, c client.Reader, opts ...client.ListOption) ([]*istioNetworking.DestinationRule, error) {
+	var list client.ObjectList
+	switch a.Version {
+	case "v1":
+		list = &istioNetworkingv1.DestinationRuleList{}
+	case "v1beta1":
+		list = &istioNetworkingv1beta1.DestinationRuleList{}
+	default:
+		list = &istioNetworking.DestinationRuleList{}
+	}
+	if err := c.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	var destinationRules []*istioNetworking.DestinationRule
+	switch list := list.(type) {
+	case *istioNetworkingv1.DestinationRuleList:
+		for _, dr := range list.Items {
+			destinationRules = append(destinationRules, destinationRuleToV1alpha3(dr))
+		}
+	case *istioNetworkingv1beta1.DestinationRuleList:
+		for _, dr := range list.Items {
+			destinationRules = append(destinationRules, destinationRuleToV1alpha3(dr))
+		}
+	case *istioNetworking.DestinationRuleList:
+		destinationRules = list.Items
+	}
+	return destinationRules, nil
+}
+
+func destinationRuleToV1alpha3(obj client.Object) *istioNetworking.DestinationRule {
+	dr := &istioNetworking.DestinationRule{}
+	switch in := obj.(type) {
+	case *istioNetworkingv1.DestinationRule:
+		in.ObjectMeta.DeepCopyInto(&dr.ObjectMeta)
+		in.Spec.DeepCopyInto(&dr.Spec)
+	case *istioNetworkingv1beta1.DestinationRule:
+		in.ObjectMeta.DeepCopyInto(&dr.ObjectMeta)
+		in.Spec.DeepCopyInto(&dr.Spec)
+	case *istioNetworking.DestinationRule:
+		dr = in
+	}
+	return dr
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	istioNetworkingv1 "istio.io/client-go/pkg/apis/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var _ = Describe("DestinationRule API", func() {
+	restMapper := func(versions ...string) meta.RESTMapper {
+		var groupVersions []schema.GroupVersion
+		for _, version := range versions {
+			groupVersions = append(groupVersions, schema.GroupVersion{Group: "networking.istio.io", Version: version})
+		}
+		mapper := meta.NewDefaultRESTMapper(groupVersions)
+		for _, gv := range groupVersions {
+			mapper.Add(gv.WithKind("DestinationRule"), meta.RESTScopeNamespace)
+		}
+		return mapper
+	}
+
+	It("should prefer the most recent served version", func() {
+		api, err := DetectDestinationRuleAPI(restMapper("v1alpha3", "v1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.Version).To(Equal("v1"))
+		Expect(api.NewObject()).To(BeAssignableToTypeOf(&istioNetworkingv1.DestinationRule{}))
+
+		api, err = DetectDestinationRuleAPI(restMapper("v1beta1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.Version).To(Equal("v1beta1"))
+	})
+
+	It("should report a NoMatch error without a served version", func() {
+		_, err := DetectDestinationRuleAPI(restMapper())
+		Expect(meta.IsNoMatchError(err)).To(BeTrue())
+	})
+
+	It("should convert a v1 DestinationRule to v1alpha3", func() {
+		dr := &istioNetworkingv1.DestinationRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		}
+		dr.Spec.Host = "api.example.com"
+		dr.Spec.TrafficPolicy = &networkingv1alpha3.TrafficPolicy{
+			Tls: &networkingv1alpha3.ClientTLSSettings{Mode: networkingv1alpha3.ClientTLSSettings_SIMPLE},
+		}
+		converted := destinationRuleToV1alpha3(dr)
+		Expect(converted.Name).To(Equal("api"))
+		Expect(converted.Spec.Host).To(Equal("api.example.com"))
+		Expect(converted.Spec.TrafficPolicy.Tls.Mode).To(Equal(networkingv1alpha3.ClientTLSSettings_SIMPLE))
+	})
+})
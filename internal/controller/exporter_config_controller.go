@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const reloadTimeout = 10 * time.Second
+
+// ExporterConfigReconciler writes the modules of the blackbox exporter declared in the config to
+// its ConfigMap and reloads the exporter once the content changed. A ConfigMap that is no longer
+// configured is kept, so the exporter keeps working.
+type ExporterConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *config.Store
+	// Reader reads the ConfigMap, the Client if not set. The cache of the manager only holds
+	// managed ConfigMaps, so an existing ConfigMap without the label is only seen uncached.
+	Reader client.Reader
+	// Namespace the ConfigMap has to be in, the only one the operator may access ConfigMaps in.
+	// Any namespace if not set.
+	Namespace string
+	// ConfigChanged triggers a reconcile of the ConfigMap, optional.
+	ConfigChanged <-chan event.GenericEvent
+	// HTTPClient is used for reloads, http.DefaultClient if not set.
+	HTTPClient *http.Client
+	// Now returns the current time, time.Now if not set.
+	Now func() time.Time
+
+	// updated is the time the operator last changed a ConfigMap, reloaded the content of the
+	// last successful reload. Reconciles of the controller do not run concurrently.
+	updated  map[types.NamespacedName]time.Time
+	reloaded map[types.NamespacedName]string
+}
+
+// The namespace of the markers is only the default of the generated Role, config/default sets it
+// to EXPORTER_NAMESPACE of the manager, which is also passed as --exporter-namespace.
+// +kubebuilder:rbac:groups="",namespace=monitoring,resources=configmaps,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups=discovery.k8s.io,namespace=monitoring,resources=endpointslices,verbs=get;list;watch
+
+func (r *ExporterConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	exporter := &r.Config.Get().Exporter
+	if !exporter.IsManaged() || req.NamespacedName != configMapKey(exporter) {
+		return ctrl.Result{}, nil
+	}
+	if r.Namespace != "" && req.Namespace != r.Namespace {
+		return ctrl.Result{}, fmt.Errorf("ConfigMap %s is not in the namespace %s of the blackbox exporter", req.NamespacedName, r.Namespace)
+	}
+	if r.updated == nil {
+		r.updated = map[types.NamespacedName]time.Time{}
+		r.reloaded = map[types.NamespacedName]string{}
+	}
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+
+	content, err := exporter.Render()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	key := exporter.ConfigMapKey()
+	reader := r.Reader
+	if reader == nil {
+		reader = r.Client
+	}
+	var cm corev1.ConfigMap
+	err = reader.Get(ctx, req.NamespacedName, &cm)
+	switch {
+	case errors.IsNotFound(err):
+		cm = corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      req.Name,
+				Namespace: req.Namespace,
+				Labels:    map[string]string{monitoring.ManagedByLabel: monitoring.ManagedByValue},
+			},
+			Data: map[string]string{key: content},
+		}
+		if err := r.Create(ctx, &cm); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("Blackbox exporter ConfigMap created", "name", req.Name, "namespace", req.Namespace)
+		r.updated[req.NamespacedName] = now()
+	case err != nil:
+		return ctrl.Result{}, err
+	case cm.Labels[monitoring.ManagedByLabel] != monitoring.ManagedByValue:
+		return ctrl.Result{}, notManagedError(req.NamespacedName)
+	case cm.Data[key] != content:
+		patch := client.MergeFrom(cm.DeepCopy())
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = content
+		if err := r.Patch(ctx, &cm, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("Blackbox exporter ConfigMap updated", "name", req.Name, "namespace", req.Namespace)
+		r.updated[req.NamespacedName] = now()
+	}
+
+	if exporter.ReloadURL == "" || r.reloaded[req.NamespacedName] == content {
+		return ctrl.Result{}, nil
+	}
+	// the kubelet needs some time to update the file mounted from the ConfigMap
+	if wait := r.updated[req.NamespacedName].Add(exporter.Delay()).Sub(now()); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	urls, err := r.reloadURLs(ctx, exporter.ReloadURL)
+	if err != nil {
+		exporterReloads.WithLabelValues("failure").Inc()
+		return ctrl.Result{}, err
+	}
+	// a failed reload retries all of them, another reload with the same content is harmless
+	var errs []error
+	for _, u := range urls {
+		if err := r.reload(ctx, u); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		exporterReloads.WithLabelValues("failure").Inc()
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
+	}
+	exporterReloads.WithLabelValues("success").Inc()
+	r.reloaded[req.NamespacedName] = content
+	logger.Info("Blackbox exporter reloaded", "url", exporter.ReloadURL, "endpoints", len(urls))
+	return ctrl.Result{}, nil
+}
+
+// reloadURLs returns the reload URL of every ready endpoint of the Service the reload URL
+// points to, so every replica of the exporter is reloaded. The reload URL is returned as it
+// is if its host is not the DNS name of a Service, <service>.<namespace>.svc[.<cluster domain>].
+func (r *ExporterConfigReconciler) reloadURLs(ctx context.Context, reloadURL string) ([]string, error) {
+	u, err := url.Parse(reloadURL)
+	if err != nil {
+		return nil, err
+	}
+	labels := strings.Split(u.Hostname(), ".")
+	if len(labels) < 3 || labels[2] != "svc" {
+		return []string{reloadURL}, nil
+	}
+	key := types.NamespacedName{Name: labels[0], Namespace: labels[1]}
+	if r.Namespace != "" && key.Namespace != r.Namespace {
+		return nil, fmt.Errorf("Service %s of the reload URL is not in the namespace %s of the blackbox exporter", key, r.Namespace)
+	}
+
+	// the EndpointSlices list the target port of the Service port by its name
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	var svc corev1.Service
+	if err := r.Get(ctx, key, &svc); err != nil {
+		return nil, err
+	}
+	portName, found := "", false
+	for _, p := range svc.Spec.Ports {
+		if strconv.Itoa(int(p.Port)) == port {
+			portName, found = p.Name, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Service %s of the reload URL has no port %s", key, port)
+	}
+
+	var slices discoveryv1.EndpointSliceList
+	if err := r.List(ctx, &slices, client.InNamespace(key.Namespace), client.MatchingLabels{discoveryv1.LabelServiceName: key.Name}); err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, slice := range slices.Items {
+		var targetPort *int32
+		for _, p := range slice.Ports {
+			if ptr.Deref(p.Name, "") == portName {
+				targetPort = p.Port
+			}
+		}
+		if targetPort == nil {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if !ptr.Deref(endpoint.Conditions.Ready, true) {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				endpointURL := *u
+				endpointURL.Host = net.JoinHostPort(address, strconv.Itoa(int(*targetPort)))
+				urls = append(urls, endpointURL.String())
+			}
+		}
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("Service %s of the reload URL has no ready endpoints", key)
+	}
+	return urls, nil
+}
+
+// reload posts to the reload endpoint of the exporter.
+func (r *ExporterConfigReconciler) reload(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, reloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("reload of the blackbox exporter failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("reload of the blackbox exporter failed with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func notManagedError(key types.NamespacedName) error {
+	return fmt.Errorf("ConfigMap %s exists and is not managed by the operator, label it with %s=%s to take it over",
+		key, monitoring.ManagedByLabel, monitoring.ManagedByValue)
+}
+
+func configMapKey(exporter *config.ExporterConfig) types.NamespacedName {
+	return types.NamespacedName{Name: exporter.ConfigMap.Name, Namespace: exporter.ConfigMap.Namespace}
+}
+
+// configuredConfigMap maps an event to a request for the configured ConfigMap.
+func (r *ExporterConfigReconciler) configuredConfigMap(_ context.Context, _ client.Object) []reconcile.Request {
+	exporter := &r.Config.Get().Exporter
+	if !exporter.IsManaged() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: configMapKey(exporter)}}
+}
+
+// SetupWithManager sets up the controller with the Manager. The configured ConfigMap is
+// reconciled on start, on config changes and when it is changed by others.
+func (r *ExporterConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the ConfigMap may not exist yet, so its watch does not trigger the first reconcile
+	start := make(chan event.GenericEvent, 1)
+	start <- event.GenericEvent{Object: &metav1.PartialObjectMetadata{}}
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("exporterconfig").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return client.ObjectKeyFromObject(obj) == configMapKey(&r.Config.Get().Exporter)
+		}))).
+		WatchesRawSource(source.Channel(start, handler.EnqueueRequestsFromMapFunc(r.configuredConfigMap)))
+	if r.ConfigChanged != nil {
+		b = b.WatchesRawSource(source.Channel(r.ConfigChanged, handler.EnqueueRequestsFromMapFunc(r.configuredConfigMap)))
+	}
+	return b.Complete(r)
+}
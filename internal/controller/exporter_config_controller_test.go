@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ExporterConfig Controller", func() {
+	Context("When the modules of the exporter are declared", func() {
+		ctx := context.Background()
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "exporter"}}
+		key := types.NamespacedName{Name: "blackbox-exporter", Namespace: namespace.Name}
+
+		var reloads atomic.Int32
+		var reloadStatus atomic.Int32
+		var server *httptest.Server
+
+		newConfig := func(modules map[string]config.Module) *config.Config {
+			return &config.Config{
+				DefaultModule: "http_2xx",
+				Exporter: config.ExporterConfig{
+					ConfigMap:   config.ExporterConfigMap{Name: key.Name, Namespace: key.Namespace},
+					ReloadURL:   server.URL + "/-/reload",
+					ReloadDelay: "0s",
+					Modules:     modules,
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			reloads.Store(0)
+			reloadStatus.Store(http.StatusOK)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/-/reload" {
+					http.NotFound(w, r)
+					return
+				}
+				reloads.Add(1)
+				w.WriteHeader(int(reloadStatus.Load()))
+			}))
+			DeferCleanup(server.Close)
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, namespace.DeepCopy()))).To(Succeed())
+		})
+
+		AfterEach(func() {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cm))).To(Succeed())
+		})
+
+		It("should write the modules to the ConfigMap and reload the exporter once per change", func() {
+			store := config.NewStore(newConfig(map[string]config.Module{"http_2xx": {Prober: "http", Timeout: "5s"}}))
+			reconciler := &ExporterConfigReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: store}
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, key, cm)).To(Succeed())
+			Expect(cm.Labels).To(HaveKeyWithValue(monitoring.ManagedByLabel, monitoring.ManagedByValue))
+			Expect(cm.Data).To(HaveKeyWithValue("blackbox.yaml", "modules:\n  http_2xx:\n    prober: http\n    timeout: 5s\n"))
+			Expect(reloads.Load()).To(Equal(int32(1)))
+
+			// unchanged content is not reloaded again
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloads.Load()).To(Equal(int32(1)))
+
+			store.Set(newConfig(map[string]config.Module{
+				"http_2xx":    {Prober: "http", Timeout: "5s"},
+				"tcp_connect": {Prober: "tcp"},
+			}))
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, cm)).To(Succeed())
+			Expect(cm.Data["blackbox.yaml"]).To(ContainSubstring("tcp_connect:\n    prober: tcp\n"))
+			Expect(reloads.Load()).To(Equal(int32(2)))
+		})
+
+		It("should wait for the reload delay and retry a failed reload", func() {
+			cfg := newConfig(map[string]config.Module{"http_2xx": {Prober: "http"}})
+			cfg.Exporter.ReloadDelay = "1m"
+			now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			reconciler := &ExporterConfigReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(cfg),
+				Now:    func() time.Time { return now },
+			}
+
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			Expect(reloads.Load()).To(BeZero())
+
+			now = now.Add(time.Minute)
+			reloadStatus.Store(http.StatusInternalServerError)
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(MatchError(ContainSubstring("reload of the blackbox exporter failed with 500")))
+
+			reloadStatus.Store(http.StatusOK)
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloads.Load()).To(Equal(int32(2)))
+		})
+
+		It("should not take over a ConfigMap that is not managed by the operator", func() {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Data:       map[string]string{"blackbox.yaml": "modules: {}\n"},
+			}
+			Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+			reconciler := &ExporterConfigReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(newConfig(map[string]config.Module{"http_2xx": {Prober: "http"}})),
+			}
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(MatchError(ContainSubstring("is not managed by the operator")))
+			Expect(k8sClient.Get(ctx, key, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("blackbox.yaml", "modules: {}\n"))
+			Expect(reloads.Load()).To(BeZero())
+		})
+
+		It("should reload every ready endpoint of the Service of the reload URL", func() {
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "blackbox-exporter", Namespace: namespace.Name},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 9115}}},
+			}
+			Expect(k8sClient.Create(ctx, svc)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, svc)
+			serverURL, err := url.Parse(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			serverPort, err := strconv.Atoi(serverURL.Port())
+			Expect(err).NotTo(HaveOccurred())
+			slice := &discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "blackbox-exporter-1",
+					Namespace: namespace.Name,
+					Labels:    map[string]string{discoveryv1.LabelServiceName: svc.Name},
+				},
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To(int32(serverPort))}},
+				Endpoints: []discoveryv1.Endpoint{
+					{Addresses: []string{"127.0.0.1"}},
+					{Addresses: []string{"127.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)}},
+					{Addresses: []string{"127.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, slice)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, slice)
+
+			cfg := newConfig(map[string]config.Module{"http_2xx": {Prober: "http"}})
+			cfg.Exporter.ReloadURL = "http://blackbox-exporter." + namespace.Name + ".svc:9115/-/reload"
+			reconciler := &ExporterConfigReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: config.NewStore(cfg)}
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloads.Load()).To(Equal(int32(2)))
+		})
+
+		It("should not write a ConfigMap outside of the namespace of the exporter", func() {
+			reconciler := &ExporterConfigReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				Config:    config.NewStore(newConfig(map[string]config.Module{"http_2xx": {Prober: "http"}})),
+				Namespace: "monitoring",
+			}
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(MatchError(ContainSubstring("is not in the namespace monitoring")))
+			Expect(k8sClient.Get(ctx, key, &corev1.ConfigMap{})).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ExternalNameServiceReconciler generates the same monitoring objects as the ServiceEntryReconciler
+// for Services of type ExternalName. The externalName is probed on every port of the Service.
+type ExternalNameServiceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *config.Store
+	// ConfigChanged triggers a reconcile of all ExternalName Services, optional.
+	ConfigChanged <-chan event.GenericEvent
+	// Recorder emits Events about the outcome of reconciles on the Services, optional.
+	Recorder events.EventRecorder
+
+	// rulesMissing is set at setup if the PrometheusRule CRD is not installed
+	rulesMissing bool
+}
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+
+// Reconcile generates the monitoring object of an ExternalName Service. The objects are deleted
+// when the Service is deleted or its type changes.
+func (r *ExternalNameServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	outputs := &sourceReconciler{Client: r.Client, Scheme: r.Scheme, Recorder: r.Recorder, SkipRules: r.rulesMissing}
+
+	var svc corev1.Service
+	if err := r.Get(ctx, req.NamespacedName, &svc); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, outputs.deleteOutputs(ctx, monitoring.KindService, req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, err
+	}
+	if !monitoring.IsExternalName(&svc) {
+		return ctrl.Result{}, outputs.deleteOutputs(ctx, monitoring.KindService, svc.Namespace, svc.Name)
+	}
+
+	logger.Info("ExternalName Service detected/modified", "name", svc.Name, "namespace", svc.Namespace)
+	return ctrl.Result{}, outputs.reconcile(ctx, r.Config.Get(), monitoring.FromService(&svc), &svc, nil)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Only Services that are or were of type ExternalName are reconciled.
+func (r *ExternalNameServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	isExternalName := func(obj client.Object) bool {
+		svc, ok := obj.(*corev1.Service)
+		return ok && monitoring.IsExternalName(svc)
+	}
+	var err error
+	if r.rulesMissing, err = rulesMissing(mgr); err != nil {
+		return err
+	}
+	b, err := sourceController(mgr, "externalnameservice", &corev1.Service{},
+		sourcePredicate(r.Config, isExternalName), r.ConfigChanged, r.externalNameServices)
+	if err != nil {
+		return err
+	}
+	return b.Complete(r)
+}
+
+// externalNameServices returns requests for the ExternalName Services in the namespace.
+func (r *ExternalNameServiceReconciler) externalNameServices(ctx context.Context, namespace string) []reconcile.Request {
+	var svcList corev1.ServiceList
+	if err := r.List(ctx, &svcList, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Services", "namespace", namespace)
+		return nil
+	}
+	var services []*corev1.Service
+	for i := range svcList.Items {
+		if svc := &svcList.Items[i]; monitoring.IsExternalName(svc) {
+			services = append(services, svc)
+		}
+	}
+	return requestsFor(services)
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ExternalName Service Controller", func() {
+	Context("When reconciling an ExternalName Service", func() {
+		ctx := context.Background()
+
+		var service *corev1.Service
+		// a ServiceEntry with the same name whose ServiceMonitor must not collide
+		var serviceEntry *istioNetworking.ServiceEntry
+		cfg := &config.Config{
+			DefaultModule:               "http_2xx",
+			ServiceMonitorNamingPattern: "sm-%s",
+			Interval:                    "10s",
+			ScrapeTimeout:               "10s",
+		}
+
+		BeforeEach(func() {
+			service = &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "default"},
+				Spec: corev1.ServiceSpec{
+					Type:         corev1.ServiceTypeExternalName,
+					ExternalName: "api.payments.example.com",
+					Ports:        []corev1.ServicePort{{Name: "https", Port: 443, Protocol: corev1.ProtocolTCP}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, service)).To(Succeed())
+			serviceEntry = &istioNetworking.ServiceEntry{
+				ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "default"},
+				Spec: v1alpha3.ServiceEntry{
+					Hosts: []string{"payments.example.com"},
+					Ports: []*v1alpha3.ServicePort{{Name: "https", Number: 443, Protocol: "HTTPS"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+			seReconciler := &ServiceEntryReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: config.NewStore(cfg)}
+			_, err := seReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, service))).To(Succeed())
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, serviceEntry))).To(Succeed())
+			for _, name := range []string{"sm-payments", "sm-payments-service"} {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &monitoringv1.ServiceMonitor{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				}))).To(Succeed())
+			}
+		})
+
+		newReconciler := func() *ExternalNameServiceReconciler {
+			return &ExternalNameServiceReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(cfg),
+			}
+		}
+
+		It("should probe the externalName and delete the ServiceMonitor when the type changes", func() {
+			controllerReconciler := newReconciler()
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-payments-service", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(serviceMonitor.Labels).To(HaveKeyWithValue(monitoring.ForKindLabel, monitoring.KindService))
+			Expect(metav1.IsControlledBy(serviceMonitor, service)).To(BeTrue())
+			Expect(serviceMonitor.Spec.Endpoints).To(HaveLen(1))
+			Expect(serviceMonitor.Spec.Endpoints[0].Params["target"]).To(Equal([]string{"api.payments.example.com:443"}))
+
+			service.Spec.Type = corev1.ServiceTypeClusterIP
+			service.Spec.ExternalName = ""
+			Expect(k8sClient.Update(ctx, service)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "sm-payments-service", Namespace: "default"}, &monitoringv1.ServiceMonitor{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			By("keeping the ServiceMonitor of the ServiceEntry with the same name")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-payments", Namespace: "default"}, &monitoringv1.ServiceMonitor{})).To(Succeed())
+		})
+
+		It("should not collide with the ServiceMonitor of a ServiceEntry with the same name", func() {
+			_, err := newReconciler().Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceEntryMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-payments", Namespace: "default"}, serviceEntryMonitor)).To(Succeed())
+			Expect(metav1.IsControlledBy(serviceEntryMonitor, serviceEntry)).To(BeTrue())
+			Expect(serviceEntryMonitor.Spec.Endpoints[0].Params["target"]).To(Equal([]string{"https://payments.example.com:443"}))
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-payments-service", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(metav1.IsControlledBy(serviceMonitor, service)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,29 @@
+package controller
+
+import "sigs.k8s.io/controller-runtime/pkg/event"
+
+// FanOut forwards every event of in to n channels, so several controllers can be triggered by
+// the same config change. Like the senders of config changes it drops an event if the receiver
+// has one pending, one pending event is enough to reconcile everything. It stops when in is closed.
+func FanOut(in <-chan event.GenericEvent, n int) []<-chan event.GenericEvent {
+	outs := make([]chan event.GenericEvent, n)
+	receivers := make([]<-chan event.GenericEvent, n)
+	for i := range outs {
+		outs[i] = make(chan event.GenericEvent, 1)
+		receivers[i] = outs[i]
+	}
+	go func() {
+		for e := range in {
+			for _, out := range outs {
+				select {
+				case out <- e:
+				default:
+				}
+			}
+		}
+		for _, out := range outs {
+			close(out)
+		}
+	}()
+	return receivers
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// HTTPRouteReconciler probes the hostnames of Gateway API HTTPRoutes on the listeners of their
+// parent Gateways with the same monitoring objects as the ServiceEntryReconciler.
+type HTTPRouteReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *config.Store
+	// ConfigChanged triggers a reconcile of all HTTPRoutes, optional.
+	ConfigChanged <-chan event.GenericEvent
+	// Recorder emits Events about the outcome of reconciles on the HTTPRoutes, optional.
+	Recorder events.EventRecorder
+
+	// rulesMissing is set at setup if the PrometheusRule CRD is not installed
+	rulesMissing bool
+}
+
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes;gateways,verbs=get;list;watch
+
+// Reconcile generates the monitoring object of an HTTPRoute.
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	outputs := &sourceReconciler{Client: r.Client, Scheme: r.Scheme, Recorder: r.Recorder, SkipRules: r.rulesMissing}
+
+	var route gatewayv1.HTTPRoute
+	if err := r.Get(ctx, req.NamespacedName, &route); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, outputs.deleteOutputs(ctx, monitoring.KindHTTPRoute, req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, err
+	}
+	gateways, err := r.parentGateways(ctx, &route)
+	if err != nil {
+		outputs.failed(ctx, monitoring.FromHTTPRoute(&route, nil), &route, err)
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("HTTPRoute detected/modified", "name", route.Name, "namespace", route.Namespace)
+	return ctrl.Result{}, outputs.reconcile(ctx, r.Config.Get(), monitoring.FromHTTPRoute(&route, gateways), &route, nil)
+}
+
+// parentGateways returns the existing Gateways the route references as parents.
+func (r *HTTPRouteReconciler) parentGateways(ctx context.Context, route *gatewayv1.HTTPRoute) ([]*gatewayv1.Gateway, error) {
+	var gateways []*gatewayv1.Gateway
+	for _, ref := range route.Spec.ParentRefs {
+		key := client.ObjectKey{Name: string(ref.Name), Namespace: route.Namespace}
+		if ref.Namespace != nil {
+			key.Namespace = string(*ref.Namespace)
+		}
+		var gateway gatewayv1.Gateway
+		if err := r.Get(ctx, key, &gateway); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		gateways = append(gateways, &gateway)
+	}
+	return gateways, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Without the Gateway API CRDs installed the controller is skipped.
+func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	for _, obj := range []client.Object{&gatewayv1.HTTPRoute{}, &gatewayv1.Gateway{}} {
+		installed, err := isInstalled(mgr, obj)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			mgr.GetLogger().Info("Gateway API CRDs not installed, HTTPRoutes are not probed")
+			return nil
+		}
+	}
+	var err error
+	if r.rulesMissing, err = rulesMissing(mgr); err != nil {
+		return err
+	}
+	b, err := sourceController(mgr, "httproute", &gatewayv1.HTTPRoute{},
+		sourcePredicate(r.Config, nil), r.ConfigChanged, r.httpRoutes)
+	if err != nil {
+		return err
+	}
+	// listener changes affect the probed hosts and ports
+	b = b.Watches(&gatewayv1.Gateway{}, handler.EnqueueRequestsFromMapFunc(r.gatewayHTTPRoutes))
+	return b.Complete(r)
+}
+
+// httpRoutes returns requests for the HTTPRoutes in the namespace.
+func (r *HTTPRouteReconciler) httpRoutes(ctx context.Context, namespace string) []reconcile.Request {
+	routes, err := r.listHTTPRoutes(ctx, client.InNamespace(namespace))
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list HTTPRoutes", "namespace", namespace)
+		return nil
+	}
+	return requestsFor(routes)
+}
+
+// gatewayHTTPRoutes maps a Gateway to requests for the HTTPRoutes referencing it as parent.
+func (r *HTTPRouteReconciler) gatewayHTTPRoutes(ctx context.Context, gateway client.Object) []reconcile.Request {
+	routes, err := r.listHTTPRoutes(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list HTTPRoutes")
+		return nil
+	}
+	var referencing []*gatewayv1.HTTPRoute
+	for _, route := range routes {
+		for _, ref := range route.Spec.ParentRefs {
+			namespace := route.Namespace
+			if ref.Namespace != nil {
+				namespace = string(*ref.Namespace)
+			}
+			if namespace == gateway.GetNamespace() && string(ref.Name) == gateway.GetName() {
+				referencing = append(referencing, route)
+				break
+			}
+		}
+	}
+	return requestsFor(referencing)
+}
+
+func (r *HTTPRouteReconciler) listHTTPRoutes(ctx context.Context, opts ...client.ListOption) ([]*gatewayv1.HTTPRoute, error) {
+	var routeList gatewayv1.HTTPRouteList
+	if err := r.List(ctx, &routeList, opts...); err != nil {
+		return nil, err
+	}
+	routes := make([]*gatewayv1.HTTPRoute, 0, len(routeList.Items))
+	for i := range routeList.Items {
+		routes = append(routes, &routeList.Items[i])
+	}
+	return routes, nil
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// IngressReconciler probes the hosts of Ingresses with the same monitoring objects as the
+// ServiceEntryReconciler.
+type IngressReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *config.Store
+	// ConfigChanged triggers a reconcile of all Ingresses, optional.
+	ConfigChanged <-chan event.GenericEvent
+	// Recorder emits Events about the outcome of reconciles on the Ingresses, optional.
+	Recorder events.EventRecorder
+
+	// rulesMissing is set at setup if the PrometheusRule CRD is not installed
+	rulesMissing bool
+}
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+
+// Reconcile generates the monitoring object of an Ingress.
+func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	outputs := &sourceReconciler{Client: r.Client, Scheme: r.Scheme, Recorder: r.Recorder, SkipRules: r.rulesMissing}
+
+	var ing networkingv1.Ingress
+	if err := r.Get(ctx, req.NamespacedName, &ing); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, outputs.deleteOutputs(ctx, monitoring.KindIngress, req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Ingress detected/modified", "name", ing.Name, "namespace", ing.Namespace)
+	return ctrl.Result{}, outputs.reconcile(ctx, r.Config.Get(), monitoring.FromIngress(&ing), &ing, nil)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var err error
+	if r.rulesMissing, err = rulesMissing(mgr); err != nil {
+		return err
+	}
+	b, err := sourceController(mgr, "ingress", &networkingv1.Ingress{},
+		sourcePredicate(r.Config, nil), r.ConfigChanged, r.ingresses)
+	if err != nil {
+		return err
+	}
+	return b.Complete(r)
+}
+
+// ingresses returns requests for the Ingresses in the namespace.
+func (r *IngressReconciler) ingresses(ctx context.Context, namespace string) []reconcile.Request {
+	var ingList networkingv1.IngressList
+	if err := r.List(ctx, &ingList, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Ingresses", "namespace", namespace)
+		return nil
+	}
+	ingresses := make([]*networkingv1.Ingress, 0, len(ingList.Items))
+	for i := range ingList.Items {
+		ingresses = append(ingresses, &ingList.Items[i])
+	}
+	return requestsFor(ingresses)
+}
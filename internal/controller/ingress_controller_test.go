@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Ingress Controller", func() {
+	Context("When reconciling an Ingress", func() {
+		ctx := context.Background()
+
+		prefix := networkingv1.PathTypePrefix
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com"}}},
+				Rules: []networkingv1.IngressRule{{
+					Host: "shop.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/cart",
+							PathType: &prefix,
+							Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+								Name: "cart",
+								Port: networkingv1.ServiceBackendPort{Number: 80},
+							}},
+						}},
+					}},
+				}},
+			},
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, ingress.DeepCopy())).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, ingress))).To(Succeed())
+		})
+
+		It("should probe the hosts and paths and delete the ServiceMonitor with the Ingress", func() {
+			controllerReconciler := &IngressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-web-ingress", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(serviceMonitor.Labels).To(HaveKeyWithValue(monitoring.ForKindLabel, monitoring.KindIngress))
+			Expect(serviceMonitor.Spec.Endpoints).To(HaveLen(1))
+			Expect(serviceMonitor.Spec.Endpoints[0].Params["target"]).To(Equal([]string{"https://shop.example.com:443/cart"}))
+
+			Expect(k8sClient.Delete(ctx, ingress)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "sm-web-ingress", Namespace: "default"}, &monitoringv1.ServiceMonitor{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should record the outcome as Events and the targets in the status annotation", func() {
+			recorder := events.NewFakeRecorder(10)
+			store := config.NewStore(&config.Config{
+				DefaultModule:               "http_2xx",
+				ServiceMonitorNamingPattern: "sm-%s",
+				Interval:                    "10s",
+				ScrapeTimeout:               "10s",
+			})
+			controllerReconciler := &IngressReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Config:   store,
+				Recorder: recorder,
+			}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal Created ServiceMonitor sm-web-ingress created with 1 targets")))
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-web-ingress", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(monitoring.ParseStatus(serviceMonitor.Annotations)).To(Equal(monitoring.Status{
+				Targets: []monitoring.TargetStatus{{Host: "shop.example.com", Target: "https://shop.example.com:443/cart", Module: "http_2xx"}},
+			}))
+
+			By("reconciling again without changes")
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+
+			By("changing the module")
+			store.Set(&config.Config{
+				DefaultModule:               "http_tls",
+				ServiceMonitorNamingPattern: "sm-%s",
+				Interval:                    "10s",
+				ScrapeTimeout:               "10s",
+			})
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal Updated ServiceMonitor sm-web-ingress updated with 1 targets")))
+
+			By("excluding the Ingress")
+			store.Set(&config.Config{
+				DefaultModule:               "http_2xx",
+				ServiceMonitorNamingPattern: "sm-%s",
+				Interval:                    "10s",
+				ScrapeTimeout:               "10s",
+				ExcludeSelector:             metav1.LabelSelector{MatchLabels: map[string]string{"probe": "false"}},
+			})
+			excluded := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, request.NamespacedName, excluded)).To(Succeed())
+			patch := client.MergeFrom(excluded.DeepCopy())
+			excluded.Labels = map[string]string{"probe": "false"}
+			Expect(k8sClient.Patch(ctx, excluded, patch)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal Excluded Not probed because of the include/exclude rules")))
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "sm-web-ingress", Namespace: "default"}, &monitoringv1.ServiceMonitor{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("reconciling the excluded Ingress again")
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should report an invalid annotation once", func() {
+			recorder := events.NewFakeRecorder(10)
+			controllerReconciler := &IngressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+				Recorder: recorder,
+			}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+			annotated := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, request.NamespacedName, annotated)).To(Succeed())
+			patch := client.MergeFrom(annotated.DeepCopy())
+			annotated.Annotations = map[string]string{monitoring.IntervalAnnotation: "often"}
+			Expect(k8sClient.Patch(ctx, annotated, patch)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning InvalidAnnotation")))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal Created")))
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-web-ingress", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(monitoring.ParseStatus(serviceMonitor.Annotations).InvalidAnnotations).To(HaveLen(1))
+
+			By("reconciling again without changes")
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+			Expect(k8sClient.Delete(ctx, serviceMonitor)).To(Succeed())
+		})
+	})
+})
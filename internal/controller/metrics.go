@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	orphanedObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blackbox_operator_orphaned_servicemonitors_total",
+			Help: "Number of orphaned generated objects found by the sweeper, by kind and action (deleted or detected in dry-run mode)",
+		},
+		[]string{"kind", "action"},
+	)
+	configReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blackbox_operator_config_reloads_total",
+			Help: "Number of config file reloads, by result (success or failure)",
+		},
+		[]string{"result"},
+	)
+	configLastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "blackbox_operator_config_last_reload_successful",
+			Help: "Whether the last config file reload was successful (1) or rejected (0)",
+		},
+	)
+	generatedObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blackbox_operator_generated_objects_total",
+			Help: "Number of generated objects changed by the operator, by output kind and action (created, updated or deleted)",
+		},
+		[]string{"kind", "action"},
+	)
+	excludedSources = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blackbox_operator_excluded_sources_total",
+			Help: "Number of Sources whose generated objects were deleted because they are no longer probed, by Source kind and reason (rules, namespace or skipped)",
+		},
+		[]string{"source_kind", "reason"},
+	)
+	mappingHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blackbox_operator_mapping_hits_total",
+			Help: "Number of targets of created or updated objects a host mapping, module mapping or module rule was applied to, by type and pattern or rule name",
+		},
+		[]string{"type", "mapping"},
+	)
+	lastSuccessfulReconcile = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blackbox_operator_last_successful_reconcile_timestamp_seconds",
+			Help: "Unix time of the last successful reconcile, by Source kind",
+		},
+		[]string{"source_kind"},
+	)
+	exporterReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blackbox_operator_exporter_reloads_total",
+			Help: "Number of reloads of the blackbox exporter after its config changed, by result (success or failure)",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedObjects, configReloads, configLastReloadSuccess,
+		generatedObjects, excludedSources, mappingHits, lastSuccessfulReconcile, exporterReloads)
+}
+
+// countMappingHits counts the mappings and module rules applied to the targets of a created or
+// updated object.
+func countMappingHits(targets []monitoring.TargetStatus) {
+	for _, t := range targets {
+		if t.HostMapping != "" {
+			mappingHits.WithLabelValues("host_mapping", t.HostMapping).Inc()
+		}
+		if t.ModuleMapping != "" {
+			mappingHits.WithLabelValues("module_mapping", t.ModuleMapping).Inc()
+		}
+		if t.Rule != "" {
+			mappingHits.WithLabelValues("module_rule", t.Rule).Inc()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"reflect"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// outputLists returns empty lists for every kind of object the operator generates.
+func outputLists() []client.ObjectList {
+	return []client.ObjectList{
+		&monitoringv1.ServiceMonitorList{},
+		&monitoringv1.ProbeList{},
+		&monitoringv1alpha1.ScrapeConfigList{},
+		&monitoringv1.PrometheusRuleList{},
+	}
+}
+
+// newOutput returns an empty object of the same kind as obj.
+func newOutput(obj client.Object) client.Object {
+	switch obj.(type) {
+	case *monitoringv1.Probe:
+		return &monitoringv1.Probe{}
+	case *monitoringv1alpha1.ScrapeConfig:
+		return &monitoringv1alpha1.ScrapeConfig{}
+	case *monitoringv1.PrometheusRule:
+		return &monitoringv1.PrometheusRule{}
+	default:
+		return &monitoringv1.ServiceMonitor{}
+	}
+}
+
+func outputEqual(a, b client.Object) bool {
+	if !reflect.DeepEqual(a.GetLabels(), b.GetLabels()) {
+		return false
+	}
+	if a.GetAnnotations()[monitoring.StatusAnnotation] != b.GetAnnotations()[monitoring.StatusAnnotation] {
+		return false
+	}
+	switch a := a.(type) {
+	case *monitoringv1.ServiceMonitor:
+		b, ok := b.(*monitoringv1.ServiceMonitor)
+		return ok && reflect.DeepEqual(a.Spec, b.Spec)
+	case *monitoringv1.Probe:
+		b, ok := b.(*monitoringv1.Probe)
+		return ok && reflect.DeepEqual(a.Spec, b.Spec)
+	case *monitoringv1alpha1.ScrapeConfig:
+		b, ok := b.(*monitoringv1alpha1.ScrapeConfig)
+		return ok && reflect.DeepEqual(a.Spec, b.Spec)
+	case *monitoringv1.PrometheusRule:
+		b, ok := b.(*monitoringv1.PrometheusRule)
+		return ok && reflect.DeepEqual(a.Spec, b.Spec)
+	}
+	return false
+}
+
+// copyOutput copies spec, labels and the status annotation of src to dst, both must be of the
+// same kind. Other annotations of dst are kept.
+func copyOutput(dst, src client.Object) {
+	dst.SetLabels(src.GetLabels())
+	setStatus(dst, src.GetAnnotations()[monitoring.StatusAnnotation])
+	switch dst := dst.(type) {
+	case *monitoringv1.ServiceMonitor:
+		dst.Spec = src.(*monitoringv1.ServiceMonitor).Spec
+	case *monitoringv1.Probe:
+		dst.Spec = src.(*monitoringv1.Probe).Spec
+	case *monitoringv1alpha1.ScrapeConfig:
+		dst.Spec = src.(*monitoringv1alpha1.ScrapeConfig).Spec
+	case *monitoringv1.PrometheusRule:
+		dst.Spec = src.(*monitoringv1.PrometheusRule).Spec
+	}
+}
+
+// setStatus sets the status annotation of obj, an empty status removes it.
+func setStatus(obj client.Object, status string) {
+	annotations := obj.GetAnnotations()
+	if status == "" {
+		delete(annotations, monitoring.StatusAnnotation)
+		obj.SetAnnotations(annotations)
+		return
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[monitoring.StatusAnnotation] = status
+	obj.SetAnnotations(annotations)
+}
+
+// outputKind returns the kind of a generated object.
+func outputKind(obj client.Object) string {
+	switch obj.(type) {
+	case *monitoringv1.Probe:
+		return monitoringv1.ProbesKind
+	case *monitoringv1alpha1.ScrapeConfig:
+		return monitoringv1alpha1.ScrapeConfigsKind
+	case *monitoringv1.PrometheusRule:
+		return monitoringv1.PrometheusRuleKind
+	default:
+		return monitoringv1.ServiceMonitorsKind
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	managedObjectsDesc = prometheus.NewDesc(
+		"blackbox_operator_managed_objects",
+		"Number of objects generated by the operator, by namespace, output kind and Source kind",
+		[]string{"namespace", "kind", "source_kind"}, nil,
+	)
+	managedTargetsDesc = prometheus.NewDesc(
+		"blackbox_operator_managed_targets",
+		"Number of probed targets of the generated objects, by namespace and module",
+		[]string{"namespace", "module"}, nil,
+	)
+)
+
+// OutputCollector exports the number of generated objects and their targets. The objects are
+// listed on every scrape, the targets are read from their status annotation.
+type OutputCollector struct {
+	Reader client.Reader
+	// Timeout of listing the objects on a scrape
+	Timeout time.Duration
+}
+
+// Describe implements prometheus.Collector.
+func (c *OutputCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedObjectsDesc
+	ch <- managedTargetsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *OutputCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	type objectKey struct{ namespace, kind, srcKind string }
+	type targetKey struct{ namespace, module string }
+	objects := map[objectKey]int{}
+	targets := map[targetKey]int{}
+	for _, list := range outputLists() {
+		err := c.Reader.List(ctx, list, client.MatchingLabels{monitoring.ManagedByLabel: monitoring.ManagedByValue})
+		if meta.IsNoMatchError(err) {
+			// CRD of this output kind is not installed
+			continue
+		}
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(managedObjectsDesc, err)
+			return
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(managedObjectsDesc, err)
+			return
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			srcKind, ok := obj.GetLabels()[monitoring.ForKindLabel]
+			if !ok {
+				srcKind = monitoring.KindServiceEntry
+			}
+			objects[objectKey{obj.GetNamespace(), outputKind(obj), srcKind}]++
+			for _, t := range monitoring.ParseStatus(obj.GetAnnotations()).Targets {
+				targets[targetKey{obj.GetNamespace(), t.Module}]++
+			}
+		}
+	}
+	for key, count := range objects {
+		ch <- prometheus.MustNewConstMetric(managedObjectsDesc, prometheus.GaugeValue, float64(count), key.namespace, key.kind, key.srcKind)
+	}
+	for key, count := range targets {
+		ch <- prometheus.MustNewConstMetric(managedTargetsDesc, prometheus.GaugeValue, float64(count), key.namespace, key.module)
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"github.com/schmiddim/blackbox-operator/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Output Collector", func() {
+	Context("When generated objects exist", func() {
+		ctx := context.Background()
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "collector"}}
+
+		serviceMonitor, err := utils.LoadServiceMonitor("./testdata/2-service-monitor.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		serviceMonitor.Name = "sm-collected"
+		serviceMonitor.Namespace = namespace.Name
+		serviceMonitor.Labels[monitoring.ForLabel] = "collected"
+		serviceMonitor.Annotations = map[string]string{monitoring.StatusAnnotation: monitoring.Status{Targets: []monitoring.TargetStatus{
+			{Target: "api.example.com:80", Module: "http_2xx"},
+			{Target: "https://api.example.com:443", Module: "http_tls"},
+			{Target: "https://www.example.com:443", Module: "http_tls"},
+		}}.String()}
+
+		BeforeEach(func() {
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, namespace.DeepCopy()))).To(Succeed())
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, serviceMonitor.DeepCopy()))).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, serviceMonitor.DeepCopy()))).To(Succeed())
+		})
+
+		It("should count the objects and their targets by module", func() {
+			registry := prometheus.NewPedanticRegistry()
+			Expect(registry.Register(&OutputCollector{Reader: k8sClient, Timeout: 10 * time.Second})).To(Succeed())
+			families, err := registry.Gather()
+			Expect(err).NotTo(HaveOccurred())
+
+			values := map[string]float64{}
+			for _, family := range families {
+				for _, metric := range family.GetMetric() {
+					labels := map[string]string{}
+					for _, label := range metric.GetLabel() {
+						labels[label.GetName()] = label.GetValue()
+					}
+					if labels["namespace"] != namespace.Name {
+						continue
+					}
+					values[family.GetName()+"/"+labels["kind"]+labels["module"]] = metric.GetGauge().GetValue()
+				}
+			}
+			Expect(values).To(Equal(map[string]float64{
+				"blackbox_operator_managed_objects/ServiceMonitor": 1,
+				"blackbox_operator_managed_targets/http_2xx":       1,
+				"blackbox_operator_managed_targets/http_tls":       2,
+			}))
+		})
+	})
+})
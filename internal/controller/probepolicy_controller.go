@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/policy"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ProbePolicyReconciler keeps the status of ProbePolicies up to date. The policies are
+// applied by the ServiceEntryReconciler, the status lists the ServiceEntries a policy applies
+// to together with all policies applied to each of them by precedence.
+type ProbePolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Config the policies are merged over, the merged config of every ServiceEntry is validated.
+	Config *config.Store
+	// ConfigChanged triggers a reconcile of all ProbePolicies, optional.
+	ConfigChanged <-chan event.GenericEvent
+	// ServiceEntryAPI is the served version of the ServiceEntry API, v1alpha3 if not set.
+	ServiceEntryAPI ServiceEntryAPI
+}
+
+// +kubebuilder:rbac:groups=blackbox.schmiddim.io,resources=probepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=blackbox.schmiddim.io,resources=probepolicies/status,verbs=get;update;patch
+
+func (r *ProbePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var pp blackboxv1alpha1.ProbePolicy
+	if err := r.Get(ctx, req.NamespacedName, &pp); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := pp.Status.DeepCopy()
+	status.ObservedGeneration = pp.Generation
+	status.ServiceEntries = nil
+
+	if errs := policy.Validate(&pp); len(errs) > 0 {
+		err := errs.ToAggregate()
+		log.FromContext(ctx).Info("ProbePolicy is invalid and not applied", "name", pp.Name, "namespace", pp.Namespace, "error", err.Error())
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               conditionTypeValid,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidPolicy",
+			Message:            err.Error(),
+			ObservedGeneration: pp.Generation,
+		})
+		return ctrl.Result{}, r.updateStatus(ctx, &pp, status)
+	}
+
+	var policyList blackboxv1alpha1.ProbePolicyList
+	if err := r.List(ctx, &policyList, client.InNamespace(pp.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	serviceEntries, err := r.ServiceEntryAPI.List(ctx, r.Client, client.InNamespace(pp.Namespace))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// the policies of a ServiceEntry are only applied if their merged config is valid
+	var mergeErrs []string
+	for _, se := range serviceEntries {
+		if !policy.Matches(&pp, se.Namespace, se.Labels) {
+			continue
+		}
+		applied := policy.Select(policyList.Items, se.Namespace, se.Labels)
+		status.ServiceEntries = append(status.ServiceEntries, blackboxv1alpha1.ProbePolicyServiceEntry{
+			Name:     se.Name,
+			Policies: policy.Names(applied),
+		})
+		if r.Config == nil {
+			continue
+		}
+		if _, err := policy.Apply(r.Config.Get(), applied); err != nil {
+			mergeErrs = append(mergeErrs, fmt.Sprintf("ServiceEntry %s: %v", se.Name, err))
+		}
+	}
+	sort.Slice(status.ServiceEntries, func(i, j int) bool {
+		return status.ServiceEntries[i].Name < status.ServiceEntries[j].Name
+	})
+
+	condition := metav1.Condition{
+		Type:               conditionTypeValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Valid",
+		Message:            "Policy is valid",
+		ObservedGeneration: pp.Generation,
+	}
+	if len(mergeErrs) > 0 {
+		sort.Strings(mergeErrs)
+		log.FromContext(ctx).Info("ProbePolicy is not applied, the merged config is invalid", "name", pp.Name, "namespace", pp.Namespace, "errors", mergeErrs)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidMergedConfig"
+		condition.Message = strings.Join(mergeErrs, "; ")
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	return ctrl.Result{}, r.updateStatus(ctx, &pp, status)
+}
+
+func (r *ProbePolicyReconciler) updateStatus(ctx context.Context, pp *blackboxv1alpha1.ProbePolicy, status *blackboxv1alpha1.ProbePolicyStatus) error {
+	if reflect.DeepEqual(&pp.Status, status) {
+		return nil
+	}
+	patch := client.MergeFrom(pp.DeepCopy())
+	pp.Status = *status
+	if err := r.Status().Patch(ctx, pp, patch); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// namespacePolicies maps an object to requests for all ProbePolicies in its namespace.
+func (r *ProbePolicyReconciler) namespacePolicies(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.policies(ctx, obj.GetNamespace())
+}
+
+// allPolicies maps an event to requests for all ProbePolicies.
+func (r *ProbePolicyReconciler) allPolicies(ctx context.Context, _ client.Object) []reconcile.Request {
+	return r.policies(ctx, "")
+}
+
+// policies returns requests for the ProbePolicies in the namespace, all of them if namespace is empty.
+func (r *ProbePolicyReconciler) policies(ctx context.Context, namespace string) []reconcile.Request {
+	var policyList blackboxv1alpha1.ProbePolicyList
+	if err := r.List(ctx, &policyList, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ProbePolicies", "namespace", namespace)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(policyList.Items))
+	for _, pp := range policyList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKey{Name: pp.Name, Namespace: pp.Namespace},
+		})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager. A change of a policy or of a
+// ServiceEntry can change the precedence of every policy in the namespace, so all of them are reconciled.
+// A config change can make the merged config of any policy valid or invalid, so all policies are
+// reconciled. Without the CRD installed the controller is skipped.
+func (r *ProbePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	installed, err := isInstalled(mgr, &blackboxv1alpha1.ProbePolicy{})
+	if err != nil {
+		return err
+	}
+	if !installed {
+		mgr.GetLogger().Info("ProbePolicy CRD not installed, policies are disabled")
+		return nil
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&blackboxv1alpha1.ProbePolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&blackboxv1alpha1.ProbePolicy{}, handler.EnqueueRequestsFromMapFunc(r.namespacePolicies),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(r.ServiceEntryAPI.NewObject(), handler.EnqueueRequestsFromMapFunc(r.namespacePolicies),
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					// only label changes affect the selection
+					return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+				},
+			}))
+	if r.ConfigChanged != nil {
+		b = b.WatchesRawSource(source.Channel(r.ConfigChanged, handler.EnqueueRequestsFromMapFunc(r.allPolicies)))
+	}
+	return b.Complete(r)
+}
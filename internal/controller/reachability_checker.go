@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"github.com/schmiddim/blackbox-operator/pkg/reachability"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reachabilityAnnotations are the annotations the ReachabilityChecker manages on ServiceEntries.
+var reachabilityAnnotations = []string{
+	reachability.StateAnnotation,
+	reachability.LastChangeAnnotation,
+	reachability.FailingHostsAnnotation,
+}
+
+// ReachabilityChecker queries the probe results of the targets generated for ServiceEntries and
+// writes the aggregated result to annotations of the ServiceEntries. It checks once on start and
+// then every Interval.
+type ReachabilityChecker struct {
+	client.Client
+	Querier reachability.Querier
+	// Interval between two checks. Zero disables the periodic check.
+	Interval time.Duration
+	// ServiceEntryAPI is the served version of the ServiceEntry API, v1alpha3 if not set.
+	ServiceEntryAPI ServiceEntryAPI
+	// Now returns the current time, time.Now if not set.
+	Now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=networking.istio.io,resources=serviceentries,verbs=patch
+
+// Start runs the checker until the context is cancelled.
+func (c *ReachabilityChecker) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("reachability-checker")
+	ctx = log.IntoContext(ctx, logger)
+
+	if err := c.Check(ctx); err != nil {
+		logger.Error(err, "check failed")
+	}
+	if c.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.Check(ctx); err != nil {
+				logger.Error(err, "check failed")
+			}
+		}
+	}
+}
+
+// Check updates the reachability annotations of all ServiceEntries. The annotations are removed
+// from ServiceEntries without probed targets. A failed query keeps the annotations of the
+// ServiceEntry.
+func (c *ReachabilityChecker) Check(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	outputs := &sourceReconciler{Client: c.Client}
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+
+	serviceEntries, err := c.ServiceEntryAPI.List(ctx, c.Client)
+	if err != nil {
+		return err
+	}
+	for _, se := range serviceEntries {
+		objs, err := outputs.listOutputs(ctx, monitoring.KindServiceEntry, se.Namespace, se.Name)
+		if err != nil {
+			return err
+		}
+		var targets []monitoring.TargetStatus
+		for _, obj := range objs {
+			targets = append(targets, monitoring.ParseStatus(obj.GetAnnotations()).Targets...)
+		}
+
+		var annotations map[string]string
+		if len(targets) > 0 {
+			success, err := c.Querier.ProbeSuccess(ctx, monitoring.KindServiceEntry, se.Namespace, se.Name)
+			if err != nil {
+				logger.Error(err, "unable to query the probe results", "name", se.Name, "namespace", se.Namespace)
+				continue
+			}
+			annotations = reachability.Annotations(se.Annotations, reachability.Aggregate(targets, success), now())
+		}
+		if err := c.annotate(ctx, c.ServiceEntryAPI.Owner(se), annotations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotate replaces the reachability annotations of the ServiceEntry if they changed.
+func (c *ReachabilityChecker) annotate(ctx context.Context, se client.Object, annotations map[string]string) error {
+	current := se.GetAnnotations()
+	changed := false
+	for _, key := range reachabilityAnnotations {
+		if current[key] != annotations[key] {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch := client.MergeFrom(se.DeepCopyObject().(client.Object))
+	updated := map[string]string{}
+	for key, value := range current {
+		updated[key] = value
+	}
+	for _, key := range reachabilityAnnotations {
+		if value, ok := annotations[key]; ok {
+			updated[key] = value
+		} else {
+			delete(updated, key)
+		}
+	}
+	se.SetAnnotations(updated)
+	if err := c.Patch(ctx, se, patch); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	log.FromContext(ctx).Info("Reachability updated", "name", se.GetName(), "namespace", se.GetNamespace(),
+		"state", annotations[reachability.StateAnnotation])
+	return nil
+}
+
+// SetupWithManager runs the checker with the Manager.
+func (c *ReachabilityChecker) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(c)
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"github.com/schmiddim/blackbox-operator/pkg/reachability"
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeQuerier returns probe results by namespace/name of ServiceEntries.
+type fakeQuerier map[string]map[string]float64
+
+func (q fakeQuerier) ProbeSuccess(_ context.Context, kind, namespace, name string) (map[string]float64, error) {
+	if kind != monitoring.KindServiceEntry {
+		return nil, nil
+	}
+	return q[namespace+"/"+name], nil
+}
+
+var _ = Describe("Reachability Checker", func() {
+	Context("When a ServiceEntry is probed", func() {
+		ctx := context.Background()
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "reachability"}}
+		serviceEntry := &istioNetworking.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: namespace.Name},
+			Spec: v1alpha3.ServiceEntry{
+				Hosts:    []string{"api.example.com", "www.example.com"},
+				Ports:    []*v1alpha3.ServicePort{{Name: "https", Number: 443, Protocol: "HTTPS"}},
+				Location: v1alpha3.ServiceEntry_MESH_EXTERNAL,
+			},
+		}
+
+		BeforeEach(func() {
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, namespace.DeepCopy()))).To(Succeed())
+			Expect(k8sClient.Create(ctx, serviceEntry.DeepCopy())).To(Succeed())
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, serviceEntry.DeepCopy()))).To(Succeed())
+		})
+
+		It("should annotate the aggregated probe results", func() {
+			now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			querier := fakeQuerier{"reachability/payments": {"https://api.example.com:443": 1, "https://www.example.com:443": 1}}
+			checker := &ReachabilityChecker{Client: k8sClient, Querier: querier, Now: func() time.Time { return now }}
+			Expect(checker.Check(ctx)).To(Succeed())
+
+			annotated := &istioNetworking.ServiceEntry{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(serviceEntry), annotated)).To(Succeed())
+			Expect(annotated.Annotations).To(HaveKeyWithValue(reachability.StateAnnotation, "reachable"))
+			Expect(annotated.Annotations).To(HaveKeyWithValue(reachability.LastChangeAnnotation, "2024-05-01T12:00:00Z"))
+			Expect(annotated.Annotations).NotTo(HaveKey(reachability.FailingHostsAnnotation))
+
+			By("failing a host")
+			now = now.Add(time.Minute)
+			querier["reachability/payments"]["https://www.example.com:443"] = 0
+			Expect(checker.Check(ctx)).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(serviceEntry), annotated)).To(Succeed())
+			Expect(annotated.Annotations).To(HaveKeyWithValue(reachability.StateAnnotation, "unreachable"))
+			Expect(annotated.Annotations).To(HaveKeyWithValue(reachability.LastChangeAnnotation, "2024-05-01T12:01:00Z"))
+			Expect(annotated.Annotations).To(HaveKeyWithValue(reachability.FailingHostsAnnotation, "www.example.com"))
+
+			By("keeping the last change while the state is the same")
+			now = now.Add(time.Minute)
+			Expect(checker.Check(ctx)).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(serviceEntry), annotated)).To(Succeed())
+			Expect(annotated.Annotations).To(HaveKeyWithValue(reachability.LastChangeAnnotation, "2024-05-01T12:01:00Z"))
+		})
+	})
+})
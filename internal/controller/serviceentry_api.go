@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	istioNetworkingv1 "istio.io/client-go/pkg/apis/networking/v1"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istioNetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceEntryVersions are the supported versions of the ServiceEntry API, most recent first.
+var serviceEntryVersions = []string{"v1", "v1beta1", "v1alpha3"}
+
+// ServiceEntryAPI reads ServiceEntries in one version of the networking.istio.io API. They are
+// converted to the v1alpha3 types used by the mappers, the spec is the same in all versions.
+// The zero value uses v1alpha3.
+type ServiceEntryAPI struct {
+	Version string
+}
+
+// DetectServiceEntryAPI returns the most recent served version of the ServiceEntry API.
+func DetectServiceEntryAPI(mapper meta.RESTMapper) (ServiceEntryAPI, error) {
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: istioNetworking.GroupName, Kind: "ServiceEntry"}, serviceEntryVersions...)
+	if err != nil {
+		return ServiceEntryAPI{}, fmt.Errorf("no supported ServiceEntry API version served: %w", err)
+	}
+	return ServiceEntryAPI{Version: mapping.GroupVersionKind.Version}, nil
+}
+
+// NewObject returns an empty ServiceEntry of the version, used to set up watches.
+func (a ServiceEntryAPI) NewObject() client.Object {
+	switch a.Version {
+	case "v1":
+		return &istioNetworkingv1.ServiceEntry{}
+	case "v1beta1":
+		return &istioNetworkingv1beta1.ServiceEntry{}
+	default:
+		return &istioNetworking.ServiceEntry{}
+	}
+}
+
+// Get fetches a ServiceEntry.
+func (a ServiceEntryAPI) Get(ctx context.Context, c client.Reader, key client.ObjectKey) (*istioNetworking.ServiceEntry, error) {
+	obj := a.NewObject()
+	if err := c.Get(ctx, key, obj); err != nil {
+		return nil, err
+	}
+	return toV1alpha3(obj), nil
+}
+
+// List lists ServiceEntries.
+func (a ServiceEntryAPI) List(ctx context.Context, c client.Reader, opts ...client.ListOption) ([]*istioNetworking.ServiceEntry, error) {
+	var list client.ObjectList
+	switch a.Version {
+	case "v1":
+		list = &istioNetworkingv1.ServiceEntryList{}
+	case "v1beta1":
+		list = &istioNetworkingv1beta1.ServiceEntryList{}
+	default:
+		list = &istioNetworking.ServiceEntryList{}
+	}
+	if err := c.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	var serviceEntries []*istioNetworking.ServiceEntry
+	switch list := list.(type) {
+	case *istioNetworkingv1.ServiceEntryList:
+		for _, se := range list.Items {
+			serviceEntries = append(serviceEntries, toV1alpha3(se))
+		}
+	case *istioNetworkingv1beta1.ServiceEntryList:
+		for _, se := range list.Items {
+			serviceEntries = append(serviceEntries, toV1alpha3(se))
+		}
+	case *istioNetworking.ServiceEntryList:
+		serviceEntries = list.Items
+	}
+	return serviceEntries, nil
+}
+
+// Owner converts a ServiceEntry back to the version, so owner references of generated
+// objects use a served version.
+func (a ServiceEntryAPI) Owner(se *istioNetworking.ServiceEntry) client.Object {
+	switch owner := a.NewObject().(type) {
+	case *istioNetworkingv1.ServiceEntry:
+		se.ObjectMeta.DeepCopyInto(&owner.ObjectMeta)
+		se.Spec.DeepCopyInto(&owner.Spec)
+		return owner
+	case *istioNetworkingv1beta1.ServiceEntry:
+		se.ObjectMeta.DeepCopyInto(&owner.ObjectMeta)
+		se.Spec.DeepCopyInto(&owner.Spec)
+		return owner
+	default:
+		return se
+	}
+}
+
+func toV1alpha3(obj client.Object) *istioNetworking.ServiceEntry {
+	se := &istioNetworking.ServiceEntry{}
+	switch in := obj.(type) {
+	case *istioNetworkingv1.ServiceEntry:
+		in.ObjectMeta.DeepCopyInto(&se.ObjectMeta)
+		in.Spec.DeepCopyInto(&se.Spec)
+	case *istioNetworkingv1beta1.ServiceEntry:
+		in.ObjectMeta.DeepCopyInto(&se.ObjectMeta)
+		in.Spec.DeepCopyInto(&se.Spec)
+	case *istioNetworking.ServiceEntry:
+		se = in
+	}
+	return se
+}
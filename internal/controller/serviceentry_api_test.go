@@ -0,0 +1,37 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var _ = Describe("ServiceEntry API", func() {
+	restMapper := func(versions ...string) meta.RESTMapper {
+		var groupVersions []schema.GroupVersion
+		for _, version := range versions {
+			groupVersions = append(groupVersions, schema.GroupVersion{Group: "networking.istio.io", Version: version})
+		}
+		mapper := meta.NewDefaultRESTMapper(groupVersions)
+		for _, gv := range groupVersions {
+			mapper.Add(gv.WithKind("ServiceEntry"), meta.RESTScopeNamespace)
+		}
+		return mapper
+	}
+
+	It("should prefer the most recent served version", func() {
+		api, err := DetectServiceEntryAPI(restMapper("v1alpha3", "v1beta1", "v1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.Version).To(Equal("v1"))
+
+		api, err = DetectServiceEntryAPI(restMapper("v1alpha3"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.Version).To(Equal("v1alpha3"))
+	})
+
+	It("should fail without a served version", func() {
+		_, err := DetectServiceEntryAPI(restMapper())
+		Expect(err).To(HaveOccurred())
+	})
+})
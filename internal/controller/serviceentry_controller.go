@@ -2,66 +2,64 @@ package controller
 
 import (
 	"context"
-	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/schmiddim/blackbox-operator/pkg/config"
 	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
 	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
-	"reflect"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // ServiceEntryReconciler reconciles a ServiceEntry object
 type ServiceEntryReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
-	Config *config.Config
+	Config *config.Store
+	// ConfigChanged triggers a reconcile of all ServiceEntries, optional.
+	ConfigChanged <-chan event.GenericEvent
+	// Recorder emits Events about the outcome of reconciles on the ServiceEntries, optional.
+	Recorder events.EventRecorder
+	// ServiceEntryAPI is the served version of the ServiceEntry API, v1alpha3 if not set.
+	ServiceEntryAPI ServiceEntryAPI
+	// DestinationRuleAPI is the served version of the DestinationRule API, v1alpha3 if not set.
+	DestinationRuleAPI DestinationRuleAPI
+
+	// rulesMissing is set at setup if the PrometheusRule CRD is not installed
+	rulesMissing bool
 }
 
 // +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=create;list;get;update;patch;delete;watch
 // +kubebuilder:rbac:groups=networking.istio.io,resources=serviceentries,verbs=create;list;get;watch
-// +kubebuilder:rbac:groups=monitoring.coreos.com/v1,resources=servicemonitors,verbs=create;list;get;update;patch;delete;watch
-
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the ServiceEntry object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/reconcile
+// +kubebuilder:rbac:groups=networking.istio.io,resources=serviceentries/finalizers,verbs=update
+// +kubebuilder:rbac:groups=networking.istio.io,resources=destinationrules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=create;list;get;update;patch;delete;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=probes,verbs=create;list;get;update;patch;delete;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=scrapeconfigs,verbs=create;list;get;update;patch;delete;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=create;list;get;update;patch;delete;watch
+// +kubebuilder:rbac:groups=blackbox.schmiddim.io,resources=probepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+
+// Reconcile creates or updates the monitoring objects of a ServiceEntry, ports with TLS
+// origination by a DestinationRule are probed with https. The objects are deleted with the
+// ServiceEntry.
 func (r *ServiceEntryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
-	smm := monitoring.NewServiceMonitorMapper(r.Config, &logger)
-	exclude := monitoring.NewExcluded(r.Config)
 	// Try to fetch the ServiceEntry
-	var se istioNetworking.ServiceEntry
-	if err := r.Get(ctx, req.NamespacedName, &se); err != nil {
+	se, err := r.ServiceEntryAPI.Get(ctx, r.Client, req.NamespacedName)
+	if err != nil {
 		if errors.IsNotFound(err) {
-			// ServiceEntry was deleted → Delete the associated ServiceMonitor
-			smName, err := smm.GetNameForServiceMonitor(req.Name)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			sm := &monitoringv1.ServiceMonitor{}
-			err = r.Get(ctx, client.ObjectKey{Name: smName, Namespace: req.Namespace}, sm)
-			if err == nil {
-				// Delete the ServiceMonitor if it exists
-				err = r.Delete(ctx, sm)
-				if err != nil {
-					return ctrl.Result{}, err
-				}
-				logger.Info("ServiceMonitor deleted", "name", smName)
-			} else if !errors.IsNotFound(err) {
-				// Return error if it's not a "not found" error
-				return ctrl.Result{}, err
-			}
-			// No further action required
-			return ctrl.Result{}, nil
+			// ServiceEntry was deleted → Delete the associated monitoring objects
+			return ctrl.Result{}, r.outputs().deleteOutputs(ctx, monitoring.KindServiceEntry, req.Namespace, req.Name)
 		}
 		// Return any other error
 		return ctrl.Result{}, err
@@ -69,59 +67,107 @@ func (r *ServiceEntryReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	logger.Info("ServiceEntry detected/modified", "name", se.Name, "namespace", se.Namespace)
 
-	// Generate the desired ServiceMonitor based on the ServiceEntry
-	sm := smm.MapperForService(&se)
+	src := monitoring.FromServiceEntry(se)
+	owner := r.ServiceEntryAPI.Owner(se)
 
-	existingSM := &monitoringv1.ServiceMonitor{}
-	err := r.Get(ctx, client.ObjectKey{Name: sm.Name, Namespace: sm.Namespace}, existingSM)
-
-	if exclude.IsExcluded(se.ObjectMeta.Labels) {
-		logger.Info("No ServiceMonitor created because of ExcludeRules", "name", se.Name, "namespace", se.Namespace)
-		if err == nil && errors.IsNotFound(err) == false {
-			logger.Info("Service Monitor exists and will be deleted", "name", existingSM.Name, "namespace", existingSM.Namespace)
-			err := r.Delete(ctx, existingSM)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-		}
-		return ctrl.Result{}, nil
+	// Ports with TLS origination by a DestinationRule are probed with https
+	destinationRules, err := r.destinationRules(ctx)
+	if err != nil {
+		r.outputs().failed(ctx, src, owner, err)
+		return ctrl.Result{}, err
 	}
+	tlsPorts := monitoring.TLSOrigination(se, destinationRules)
 
-	if err != nil && errors.IsNotFound(err) && exclude.IsExcluded(se.ObjectMeta.Labels) == false {
-		// ServiceMonitor does not exist Create it
-		err = r.Create(ctx, sm)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
-		logger.Info("ServiceMonitor created", "name", sm.Name)
-	} else if err == nil {
-		// Compare existing ServiceMonitor with desired state to avoid unnecessary updates
-		if !serviceMonitorEqual(existingSM, sm) {
-			patch := client.MergeFrom(existingSM.DeepCopy())
-			existingSM.Spec = sm.Spec
-			existingSM.Labels = sm.Labels
-			err = r.Patch(ctx, existingSM, patch)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			logger.Info("ServiceMonitor updated", "name", sm.Name)
-		} else {
-			logger.Info("ServiceMonitor unchanged", "name", sm.Name)
+	return ctrl.Result{}, r.outputs().reconcile(ctx, r.Config.Get(), src, owner, tlsPorts)
+}
+
+func (r *ServiceEntryReconciler) outputs() *sourceReconciler {
+	return &sourceReconciler{Client: r.Client, Scheme: r.Scheme, Recorder: r.Recorder, SkipRules: r.rulesMissing}
+}
+
+// isNamespaceSelected reports whether ServiceEntries in the namespace are probed.
+// The Namespace is only fetched if the selection depends on its labels.
+func isNamespaceSelected(ctx context.Context, c client.Reader, cfg *config.Config, namespace string) (bool, error) {
+	filter := monitoring.NewNamespaceFilter(cfg)
+	var ns corev1.Namespace
+	if filter.NeedsLabels() {
+		if err := c.Get(ctx, client.ObjectKey{Name: namespace}, &ns); client.IgnoreNotFound(err) != nil {
+			return false, err
 		}
-	} else {
-		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+	return filter.IsSelected(namespace, ns.Labels), nil
 }
 
-func serviceMonitorEqual(a, b *monitoringv1.ServiceMonitor) bool {
-	return reflect.DeepEqual(a.Spec, b.Spec) && reflect.DeepEqual(a.Labels, b.Labels)
+// destinationRules returns all DestinationRules, none if the CRD is not installed.
+func (r *ServiceEntryReconciler) destinationRules(ctx context.Context) ([]*istioNetworking.DestinationRule, error) {
+	destinationRules, err := r.DestinationRuleAPI.List(ctx, r.Client)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	return destinationRules, err
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. DestinationRules reconcile
+// the ServiceEntries whose hosts they match.
 func (r *ServiceEntryReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&istioNetworking.ServiceEntry{}).
-		Owns(&monitoringv1.ServiceMonitor{}).
-		Complete(r)
+	var err error
+	if r.rulesMissing, err = rulesMissing(mgr); err != nil {
+		return err
+	}
+	b, err := sourceController(mgr, "serviceentry", r.ServiceEntryAPI.NewObject(),
+		sourcePredicate(r.Config, nil), r.ConfigChanged, r.serviceEntries)
+	if err != nil {
+		return err
+	}
+	installed, err := isInstalled(mgr, r.DestinationRuleAPI.NewObject())
+	if err != nil {
+		return err
+	}
+	if installed {
+		b = b.Watches(r.DestinationRuleAPI.NewObject(), handler.EnqueueRequestsFromMapFunc(r.destinationRuleServiceEntries))
+	}
+	return b.Complete(r)
+}
+
+// serviceEntries returns requests for the ServiceEntries in the namespace.
+func (r *ServiceEntryReconciler) serviceEntries(ctx context.Context, namespace string) []reconcile.Request {
+	serviceEntries, err := r.ServiceEntryAPI.List(ctx, r.Client, client.InNamespace(namespace))
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ServiceEntries", "namespace", namespace)
+		return nil
+	}
+	return requestsFor(serviceEntries)
+}
+
+// destinationRuleServiceEntries maps a DestinationRule to requests for the ServiceEntries whose
+// hosts it matches. On updates it is called for the old and the new object.
+func (r *ServiceEntryReconciler) destinationRuleServiceEntries(ctx context.Context, obj client.Object) []reconcile.Request {
+	dr := destinationRuleToV1alpha3(obj)
+	serviceEntries, err := r.ServiceEntryAPI.List(ctx, r.Client)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ServiceEntries")
+		return nil
+	}
+	var matching []*istioNetworking.ServiceEntry
+	for _, se := range serviceEntries {
+		if monitoring.DestinationRuleMatches(dr, se) {
+			matching = append(matching, se)
+		}
+	}
+	return requestsFor(matching)
+}
+
+// isInstalled reports whether the CRD of the object is installed in the cluster.
+func isInstalled(mgr ctrl.Manager, obj client.Object) (bool, error) {
+	gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+	if err != nil {
+		return false, err
+	}
+	if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
@@ -5,12 +5,16 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
 	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
 	"github.com/schmiddim/blackbox-operator/test/utils"
 	"istio.io/api/networking/v1alpha3"
+	istioNetworkingv1 "istio.io/client-go/pkg/apis/networking/v1"
 	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -47,7 +51,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 			controllerReconciler := &ServiceEntryReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Config: &config.Config{
+				Config: config.NewStore(&config.Config{
 					LogLevel:                    "info",
 					DefaultModule:               "http_2xx",
 					ServiceMonitorNamingPattern: "sm-%s",
@@ -55,7 +59,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 					ScrapeTimeout:               "10s",
 					LabelSelector:               metav1.LabelSelector{},
 					ProtocolModuleMappings:      nil,
-				},
+				}),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -119,7 +123,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 			controllerReconciler := &ServiceEntryReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Config: &config.Config{
+				Config: config.NewStore(&config.Config{
 					LogLevel:                    "info",
 					DefaultModule:               "http_2xx",
 					ServiceMonitorNamingPattern: "sm-%s",
@@ -127,7 +131,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 					ScrapeTimeout:               "10s",
 					LabelSelector:               metav1.LabelSelector{},
 					ProtocolModuleMappings:      nil,
-				},
+				}),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -178,7 +182,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 			controllerReconciler := &ServiceEntryReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Config: &config.Config{
+				Config: config.NewStore(&config.Config{
 					LogLevel:                    "info",
 					DefaultModule:               "http_2xx",
 					ServiceMonitorNamingPattern: "sm-%s",
@@ -189,7 +193,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 						MatchLabels: map[string]string{"skip-probe-for-port": "8200"},
 					},
 					ProtocolModuleMappings: nil,
-				},
+				}),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -266,7 +270,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 			controllerReconciler := &ServiceEntryReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Config: &config.Config{
+				Config: config.NewStore(&config.Config{
 					LogLevel:                    "info",
 					DefaultModule:               "http_2xx",
 					ServiceMonitorNamingPattern: "sm-%s",
@@ -275,7 +279,7 @@ var _ = Describe("ServiceEntry Controller", func() {
 					LabelSelector:               metav1.LabelSelector{},
 					ExcludeSelector:             metav1.LabelSelector{},
 					ProtocolModuleMappings:      nil,
-				},
+				}),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -291,8 +295,326 @@ var _ = Describe("ServiceEntry Controller", func() {
 			}
 			err = k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(metav1.IsControlledBy(resource, serviceentry)).To(BeTrue())
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When reconciling a ServiceEntry selected by a ProbePolicy", func() {
+		ctx := context.Background()
+
+		const resourceSeName = "external-service-1-probe"
+		typedNsServiceEntry := types.NamespacedName{
+			Name:      resourceSeName,
+			Namespace: "default",
+		}
+		typedNsServiceMonitor := types.NamespacedName{
+			Name:      "sm-" + resourceSeName,
+			Namespace: "default",
+		}
+		serviceEntry, err := utils.LoadServiceEntry("./testdata/2-service-entry.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		probePolicy := &blackboxv1alpha1.ProbePolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-policy", Namespace: "default"},
+			Spec: blackboxv1alpha1.ProbePolicySpec{
+				Selector:               metav1.LabelSelector{MatchLabels: map[string]string{"managed-by": "istio-operator"}},
+				Interval:               "1m",
+				ProtocolModuleMappings: map[string]string{"HTTPS": "http_tls"},
+			},
+		}
+
+		BeforeEach(func() {
+			By("Creating ServiceEntry and ProbePolicy")
+			err := k8sClient.Get(ctx, typedNsServiceEntry, serviceEntry)
+			if err != nil && errors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+			}
+			Expect(k8sClient.Create(ctx, probePolicy)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, probePolicy)).To(Succeed())
+		})
+
+		It("should merge the policy over the global config and report it in the status", func() {
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typedNsServiceEntry})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, typedNsServiceMonitor, serviceMonitor)).To(Succeed())
+			Expect(serviceMonitor.Spec.Endpoints).NotTo(BeEmpty())
+			for _, endpoint := range serviceMonitor.Spec.Endpoints {
+				Expect(endpoint.Interval).To(Equal(monitoringv1.Duration("1m")))
+				Expect(endpoint.ScrapeTimeout).To(Equal(monitoringv1.Duration("10s")))
+				Expect(endpoint.Params["module"]).To(Equal([]string{"http_tls"}))
+			}
+
+			policyReconciler := &ProbePolicyReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err = policyReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(probePolicy)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(probePolicy), probePolicy)).To(Succeed())
+			Expect(probePolicy.Status.ServiceEntries).To(ContainElement(blackboxv1alpha1.ProbePolicyServiceEntry{
+				Name:     resourceSeName,
+				Policies: []string{"team-policy"},
+			}))
+		})
+	})
+
+	Context("When reconciling a ServiceEntry in a namespace that is not selected", func() {
+		ctx := context.Background()
+
+		const resourceSeName = "external-service-1-probe"
+		typedNsServiceEntry := types.NamespacedName{
+			Name:      resourceSeName,
+			Namespace: "default",
+		}
+		typedNsServiceMonitor := types.NamespacedName{
+			Name:      "sm-" + resourceSeName,
+			Namespace: "default",
+		}
+		serviceEntry, err := utils.LoadServiceEntry("./testdata/2-service-entry.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		serviceMonitor, err := utils.LoadServiceMonitor("./testdata/2-service-monitor.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		BeforeEach(func() {
+			By("Creating ServiceMonitor and ServiceEntry")
+			err := k8sClient.Get(ctx, typedNsServiceMonitor, serviceMonitor)
+			if err != nil && errors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, serviceMonitor)).To(Succeed())
+			}
+			err = k8sClient.Get(ctx, typedNsServiceEntry, serviceEntry)
+			if err != nil && errors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+			}
+		})
+
+		It("should delete the existing ServiceMonitor", func() {
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+					Namespaces:                  []string{"other"},
+				}),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typedNsServiceEntry})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, typedNsServiceMonitor, &monitoringv1.ServiceMonitor{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When reconciling a ServiceEntry whose targets are all skipped", func() {
+		ctx := context.Background()
+
+		serviceEntry := &istioNetworking.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "all-skipped",
+				Namespace:   "default",
+				Annotations: map[string]string{monitoring.SkipHostsAnnotation: "*"},
+			},
+			Spec: v1alpha3.ServiceEntry{
+				Hosts: []string{"www.example.com"},
+				Ports: []*v1alpha3.ServicePort{{Name: "https", Number: 443, Protocol: "HTTPS"}},
+			},
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, serviceEntry)).To(Succeed())
+		})
+
+		It("should not create a ServiceMonitor", func() {
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "sm-all-skipped", Namespace: "default"}, &monitoringv1.ServiceMonitor{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When reconciling a ServiceEntry with TLS origination by a DestinationRule", func() {
+		ctx := context.Background()
+
+		serviceEntry := &istioNetworking.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{Name: "tls-origination", Namespace: "default"},
+			Spec: v1alpha3.ServiceEntry{
+				Hosts: []string{"api.example.com"},
+				Ports: []*v1alpha3.ServicePort{{Name: "http", Number: 80, Protocol: "HTTP", TargetPort: 443}},
+			},
+		}
+		destinationRule := &istioNetworking.DestinationRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "tls-origination", Namespace: "default"},
+			Spec: v1alpha3.DestinationRule{
+				Host: "api.example.com",
+				TrafficPolicy: &v1alpha3.TrafficPolicy{PortLevelSettings: []*v1alpha3.TrafficPolicy_PortTrafficPolicy{{
+					Port: &v1alpha3.PortSelector{Number: 80},
+					Tls:  &v1alpha3.ClientTLSSettings{Mode: v1alpha3.ClientTLSSettings_SIMPLE},
+				}}},
+			},
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+			Expect(k8sClient.Create(ctx, destinationRule)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, destinationRule)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, serviceEntry)).To(Succeed())
+		})
+
+		It("should probe the upstream port with https", func() {
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-tls-origination", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(serviceMonitor.Spec.Endpoints).To(HaveLen(1))
+			Expect(serviceMonitor.Spec.Endpoints[0].Params["target"]).To(Equal([]string{"https://api.example.com:443"}))
+		})
+	})
+
+	Context("When reconciling a ServiceEntry of the v1 API", func() {
+		ctx := context.Background()
+
+		serviceEntry := &istioNetworkingv1.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{Name: "v1-api", Namespace: "default"},
+			Spec: v1alpha3.ServiceEntry{
+				Hosts: []string{"www.example.com"},
+				Ports: []*v1alpha3.ServicePort{{Name: "https", Number: 443, Protocol: "HTTPS"}},
+			},
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, serviceEntry)).To(Succeed())
+		})
+
+		It("should own the ServiceMonitor with the v1 API", func() {
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(&config.Config{
+					DefaultModule:               "http_2xx",
+					ServiceMonitorNamingPattern: "sm-%s",
+					Interval:                    "10s",
+					ScrapeTimeout:               "10s",
+				}),
+				ServiceEntryAPI: ServiceEntryAPI{Version: "v1"},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceMonitor := &monitoringv1.ServiceMonitor{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-v1-api", Namespace: "default"}, serviceMonitor)).To(Succeed())
+			Expect(serviceMonitor.OwnerReferences).To(HaveLen(1))
+			Expect(serviceMonitor.OwnerReferences[0].APIVersion).To(Equal("networking.istio.io/v1"))
+			Expect(serviceMonitor.Spec.Endpoints).To(HaveLen(1))
+		})
+	})
+
+	Context("When reconciling a ServiceEntry with alerts enabled", func() {
+		ctx := context.Background()
+
+		serviceEntry := &istioNetworking.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "alerted",
+				Namespace:   "default",
+				Annotations: map[string]string{monitoring.SeverityAnnotation: "critical"},
+			},
+			Spec: v1alpha3.ServiceEntry{
+				Hosts: []string{"www.example.com"},
+				Ports: []*v1alpha3.ServicePort{{Name: "https", Number: 443, Protocol: "HTTPS"}},
+			},
+		}
+		cfg := &config.Config{
+			DefaultModule:               "http_2xx",
+			ServiceMonitorNamingPattern: "sm-%s",
+			Interval:                    "10s",
+			ScrapeTimeout:               "10s",
+			Alerts:                      config.AlertsConfig{Enabled: true},
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, serviceEntry.DeepCopy())).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, serviceEntry.DeepCopy())).To(Succeed())
+		})
+
+		It("should create the PrometheusRule next to the ServiceMonitor and delete it once alerts are disabled", func() {
+			store := config.NewStore(cfg)
+			controllerReconciler := &ServiceEntryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: store,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+
+			rule := &monitoringv1.PrometheusRule{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-alerted", Namespace: "default"}, rule)).To(Succeed())
+			Expect(rule.Labels).To(HaveKeyWithValue(monitoring.ForLabel, "alerted"))
+			Expect(rule.Spec.Groups).To(HaveLen(1))
+			Expect(rule.Spec.Groups[0].Rules).To(HaveLen(2))
+			for _, r := range rule.Spec.Groups[0].Rules {
+				Expect(r.Labels).To(HaveKeyWithValue("severity", "critical"))
+				Expect(r.Expr.String()).To(ContainSubstring(`for="alerted"`))
+			}
+
+			disabled := *cfg
+			disabled.Alerts = config.AlertsConfig{}
+			store.Set(&disabled)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(serviceEntry)})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "sm-alerted", Namespace: "default"}, &monitoringv1.PrometheusRule{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sm-alerted", Namespace: "default"}, &monitoringv1.ServiceMonitor{})).To(Succeed())
+		})
+	})
 })
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ServiceMonitorAdopter sets the ServiceEntry as controller owner of ServiceMonitors
+// that were created by the operator before owner references were introduced.
+// It runs once when the manager starts.
+type ServiceMonitorAdopter struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// ServiceEntryAPI is the served version of the ServiceEntry API, v1alpha3 if not set.
+	ServiceEntryAPI ServiceEntryAPI
+}
+
+// Start adopts all managed ServiceMonitors without a controller reference.
+// ServiceMonitors whose ServiceEntry no longer exists are left untouched.
+func (a *ServiceMonitorAdopter) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("servicemonitor-adopter")
+
+	var smList monitoringv1.ServiceMonitorList
+	if err := a.List(ctx, &smList, client.MatchingLabels{monitoring.ManagedByLabel: monitoring.ManagedByValue}); err != nil {
+		return err
+	}
+
+	for i := range smList.Items {
+		sm := &smList.Items[i]
+		if metav1.GetControllerOf(sm) != nil || !isForKind(sm, monitoring.KindServiceEntry) {
+			continue
+		}
+		seName, ok := sm.Labels[monitoring.ForLabel]
+		if !ok {
+			logger.Info("ServiceMonitor has no ServiceEntry label, skipping", "name", sm.Name, "namespace", sm.Namespace)
+			continue
+		}
+
+		se, err := a.ServiceEntryAPI.Get(ctx, a.Client, client.ObjectKey{Name: seName, Namespace: sm.Namespace})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				logger.Info("ServiceEntry for ServiceMonitor not found, skipping", "name", sm.Name, "namespace", sm.Namespace)
+				continue
+			}
+			return err
+		}
+
+		patch := client.MergeFrom(sm.DeepCopy())
+		if err := controllerutil.SetControllerReference(a.ServiceEntryAPI.Owner(se), sm, a.Scheme); err != nil {
+			return err
+		}
+		if err := a.Patch(ctx, sm, patch); err != nil {
+			return err
+		}
+		logger.Info("ServiceMonitor adopted", "name", sm.Name, "namespace", sm.Namespace, "serviceEntry", se.Name)
+	}
+	return nil
+}
+
+// SetupWithManager registers the adopter as a runnable of the Manager.
+func (a *ServiceMonitorAdopter) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(a)
+}
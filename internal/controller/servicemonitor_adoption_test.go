@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/test/utils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("ServiceMonitor Adopter", func() {
+	Context("When a managed ServiceMonitor has no owner reference", func() {
+		ctx := context.Background()
+
+		serviceEntry, err := utils.LoadServiceEntry("./testdata/2-service-entry.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		serviceEntry.Name = "adopt-me"
+		serviceMonitor, err := utils.LoadServiceMonitor("./testdata/2-service-monitor.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		serviceMonitor.Name = "sm-adopt-me"
+		serviceMonitor.Labels["for"] = serviceEntry.Name
+
+		BeforeEach(func() {
+			By("Creating ServiceEntry")
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: serviceEntry.Name, Namespace: serviceEntry.Namespace}, serviceEntry)
+			if err != nil && errors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, serviceEntry)).To(Succeed())
+			}
+			By("Creating ServiceMonitor")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: serviceMonitor.Name, Namespace: serviceMonitor.Namespace}, serviceMonitor)
+			if err != nil && errors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, serviceMonitor)).To(Succeed())
+			}
+		})
+
+		It("should set the ServiceEntry as controller", func() {
+			adopter := &ServiceMonitorAdopter{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			Expect(adopter.Start(ctx)).To(Succeed())
+
+			resource := &monitoringv1.ServiceMonitor{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: serviceMonitor.Name, Namespace: serviceMonitor.Namespace}, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metav1.IsControlledBy(resource, serviceEntry)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ServiceMonitorSweeper deletes managed ServiceMonitors, Probes, ScrapeConfigs and PrometheusRules
+// whose source no longer exists.
+// This covers ServiceEntries that were deleted while the operator was not running.
+// It sweeps once on start and then every Interval.
+type ServiceMonitorSweeper struct {
+	client.Client
+	// Interval between two sweeps. Zero disables the periodic sweep.
+	Interval time.Duration
+	// DryRun only reports orphans instead of deleting them.
+	DryRun bool
+	// ServiceEntryAPI is the served version of the ServiceEntry API, v1alpha3 if not set.
+	ServiceEntryAPI ServiceEntryAPI
+}
+
+// Start runs the sweeper until the context is cancelled.
+func (s *ServiceMonitorSweeper) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("servicemonitor-sweeper")
+	ctx = log.IntoContext(ctx, logger)
+
+	if err := s.Sweep(ctx); err != nil {
+		logger.Error(err, "sweep failed")
+	}
+	if s.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.Sweep(ctx); err != nil {
+				logger.Error(err, "sweep failed")
+			}
+		}
+	}
+}
+
+// Sweep lists the managed objects of every output kind whose CRD is installed once and removes
+// the orphans.
+func (s *ServiceMonitorSweeper) Sweep(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	// a failed object does not stop the sweep of the others
+	var errs []error
+	for _, list := range outputLists() {
+		err := s.List(ctx, list, client.MatchingLabels{monitoring.ManagedByLabel: monitoring.ManagedByValue})
+		if meta.IsNoMatchError(err) {
+			// CRD of this output kind is not installed
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			kind := outputKind(obj)
+			orphaned, err := s.isOrphaned(ctx, obj)
+			if err != nil {
+				logger.Error(err, "unable to resolve the source of the object", "kind", kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+				errs = append(errs, err)
+				continue
+			}
+			if !orphaned {
+				continue
+			}
+
+			if s.DryRun {
+				orphanedObjects.WithLabelValues(kind, "detected").Inc()
+				logger.Info("Orphaned object found (dry-run)", "kind", kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+				continue
+			}
+			if err := s.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				logger.Error(err, "unable to delete the orphaned object", "kind", kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+				errs = append(errs, err)
+				continue
+			}
+			orphanedObjects.WithLabelValues(kind, "deleted").Inc()
+			logger.Info("Orphaned object deleted", "kind", kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// isOrphaned resolves the source of a generated object via its controller reference or, for
+// objects that were never adopted, via the for label. Sources other than ServiceEntries are
+// identified by the for-kind label, ExternalName Services that changed their type are gone.
+func (s *ServiceMonitorSweeper) isOrphaned(ctx context.Context, obj client.Object) (bool, error) {
+	srcKind := monitoring.KindServiceEntry
+	if forKind, ok := obj.GetLabels()[monitoring.ForKindLabel]; ok {
+		srcKind = forKind
+	}
+	srcName := obj.GetLabels()[monitoring.ForLabel]
+	if owner := metav1.GetControllerOf(obj); owner != nil && owner.Kind == srcKind {
+		srcName = owner.Name
+	}
+	if srcName == "" {
+		return true, nil
+	}
+	key := client.ObjectKey{Name: srcName, Namespace: obj.GetNamespace()}
+
+	if srcKind == monitoring.KindServiceEntry {
+		_, err := s.ServiceEntryAPI.Get(ctx, s.Client, key)
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	src := newSourceObject(srcKind)
+	if src == nil {
+		return true, nil
+	}
+	err := s.Get(ctx, key, src)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return true, nil
+	}
+	if svc, ok := src.(*corev1.Service); ok && err == nil {
+		return !monitoring.IsExternalName(svc), nil
+	}
+	return false, err
+}
+
+// SetupWithManager registers the sweeper as a runnable of the Manager.
+func (s *ServiceMonitorSweeper) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(s)
+}
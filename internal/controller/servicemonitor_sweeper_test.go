@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"github.com/schmiddim/blackbox-operator/test/utils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ServiceMonitor Sweeper", func() {
+	Context("When a managed ServiceMonitor has no ServiceEntry", func() {
+		ctx := context.Background()
+
+		serviceMonitor, err := utils.LoadServiceMonitor("./testdata/2-service-monitor.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		serviceMonitor.Name = "sm-orphan"
+		serviceMonitor.Labels["for"] = "orphan"
+		typedNsServiceMonitor := types.NamespacedName{Name: serviceMonitor.Name, Namespace: serviceMonitor.Namespace}
+
+		BeforeEach(func() {
+			By("Creating ServiceMonitor")
+			err := k8sClient.Get(ctx, typedNsServiceMonitor, &monitoringv1.ServiceMonitor{})
+			if err != nil && errors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, serviceMonitor.DeepCopy())).To(Succeed())
+			}
+		})
+
+		It("should only report the orphan in dry-run mode", func() {
+			sweeper := &ServiceMonitorSweeper{Client: k8sClient, DryRun: true}
+			Expect(sweeper.Sweep(ctx)).To(Succeed())
+			Expect(k8sClient.Get(ctx, typedNsServiceMonitor, &monitoringv1.ServiceMonitor{})).To(Succeed())
+		})
+
+		It("should delete the orphan", func() {
+			sweeper := &ServiceMonitorSweeper{Client: k8sClient}
+			Expect(sweeper.Sweep(ctx)).To(Succeed())
+			err := k8sClient.Get(ctx, typedNsServiceMonitor, &monitoringv1.ServiceMonitor{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should delete orphans of the other output kinds", func() {
+			rule := &monitoringv1.PrometheusRule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "sm-orphan",
+					Namespace: serviceMonitor.Namespace,
+					Labels:    map[string]string{monitoring.ManagedByLabel: monitoring.ManagedByValue, monitoring.ForLabel: "orphan"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, rule)).To(Succeed())
+
+			// the CRDs of Probes and ScrapeConfigs are not installed
+			sweeper := &ServiceMonitorSweeper{Client: k8sClient}
+			Expect(sweeper.Sweep(ctx)).To(Succeed())
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(rule), &monitoringv1.PrometheusRule{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
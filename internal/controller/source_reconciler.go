@@ -0,0 +1,458 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+	"github.com/schmiddim/blackbox-operator/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// sourceReconciler creates, updates and deletes the monitoring objects of a Source. It is
+// shared by the reconcilers of all Source kinds.
+type sourceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+	// SkipRules is set if the PrometheusRule CRD is not installed, no alerts are generated then.
+	SkipRules bool
+}
+
+// Reasons of the Events recorded on a Source for the outcome of a reconcile.
+const (
+	reasonCreated  = "Created"
+	reasonUpdated  = "Updated"
+	reasonExcluded = "Excluded"
+	reasonFailed   = "Failed"
+)
+
+// reconcile generates the monitoring object of the Source owned by owner and deletes those
+// that are no longer desired. Ports in tlsPorts are probed with https on their upstream port.
+// Every outcome but an unchanged object is recorded as Event on owner, a failure additionally
+// as last error in the status annotation of the existing objects.
+func (r *sourceReconciler) reconcile(ctx context.Context, cfg *config.Config, src *monitoring.Source, owner client.Object, tlsPorts monitoring.TLSPorts) error {
+	err := r.apply(ctx, cfg, src, owner, tlsPorts)
+	if err != nil {
+		r.failed(ctx, src, owner, err)
+		return err
+	}
+	lastSuccessfulReconcile.WithLabelValues(src.Kind).SetToCurrentTime()
+	return nil
+}
+
+func (r *sourceReconciler) apply(ctx context.Context, cfg *config.Config, src *monitoring.Source, owner client.Object, tlsPorts monitoring.TLSPorts) error {
+	logger := log.FromContext(ctx)
+
+	if monitoring.NewExcluded(cfg).IsExcluded(src.Labels) {
+		logger.Info("No ServiceMonitor created because of include/exclude rules", "kind", src.Kind, "name", src.Name, "namespace", src.Namespace)
+		return r.exclude(ctx, src, owner, "rules", "Not probed because of the include/exclude rules")
+	}
+
+	selected, err := isNamespaceSelected(ctx, r.Client, cfg, src.Namespace)
+	if err != nil {
+		return err
+	}
+	if !selected {
+		logger.Info("No ServiceMonitor created because the namespace is not selected", "kind", src.Kind, "name", src.Name, "namespace", src.Namespace)
+		return r.exclude(ctx, src, owner, "namespace", "Not probed because namespace %s is not selected", src.Namespace)
+	}
+
+	// ProbePolicies of the namespace override the global config
+	policies, err := r.probePolicies(ctx, src.Namespace)
+	if err != nil {
+		return err
+	}
+	if applied := policy.Select(policies, src.Namespace, src.Labels); len(applied) > 0 {
+		merged, err := policy.Apply(cfg, applied)
+		if err != nil {
+			// reported in the status of the policies
+			logger.Info("Ignoring ProbePolicies, the merged config is invalid", "kind", src.Kind, "name", src.Name, "namespace", src.Namespace, "error", err.Error())
+		} else {
+			logger.Info("Applying ProbePolicies", "kind", src.Kind, "name", src.Name, "namespace", src.Namespace, "policies", policy.Names(applied))
+			cfg = merged
+		}
+	}
+	mapper := monitoring.NewMapper(cfg, &logger)
+	targets := monitoring.GenerateTargets(cfg, &logger, src, tlsPorts)
+
+	// Generate the desired ServiceMonitor, Probe or ScrapeConfig based on the Source
+	desired, err := mapper.Map(src, targets)
+	if err != nil {
+		return err
+	}
+	if desired == nil {
+		logger.Info("No ServiceMonitor created because every target is skipped", "kind", src.Kind, "name", src.Name, "namespace", src.Namespace)
+		return r.exclude(ctx, src, owner, "skipped", "Not probed because every target is skipped")
+	}
+	status := monitoring.Summarize(targets)
+	// Invalid annotations are ignored by the mapper
+	if err := r.reportInvalidAnnotations(ctx, owner, desired, status.InvalidAnnotations); err != nil {
+		return err
+	}
+	setStatus(desired, status.String())
+	changed, err := r.createOrPatch(ctx, owner, desired, fmt.Sprintf("with %d targets", len(status.Targets)))
+	if err != nil {
+		return err
+	}
+	if changed {
+		countMappingHits(status.Targets)
+	}
+	keep := []client.Object{desired}
+
+	// Alerts for the targets are optional
+	if !r.SkipRules {
+		alerts, err := monitoring.MapAlerts(cfg, src, targets)
+		if err != nil {
+			return err
+		}
+		if alerts != nil {
+			if _, err := r.createOrPatch(ctx, owner, alerts, fmt.Sprintf("with %d alerts", len(alerts.Spec.Groups[0].Rules))); err != nil {
+				return err
+			}
+			keep = append(keep, alerts)
+		}
+	}
+
+	// Remove objects of another output kind or with a previous naming pattern
+	return r.deleteOutputs(ctx, src.Kind, src.Namespace, src.Name, keep...)
+}
+
+// reportInvalidAnnotations records each of the invalid annotations as Warning Event on owner,
+// unless the status of the existing object already lists it, so it is reported once.
+func (r *sourceReconciler) reportInvalidAnnotations(ctx context.Context, owner, desired client.Object, invalid []string) error {
+	if len(invalid) == 0 {
+		return nil
+	}
+	log.FromContext(ctx).Info("Ignoring invalid annotations", "name", owner.GetName(), "namespace", owner.GetNamespace(), "errors", invalid)
+	existing := newOutput(desired)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	reported := monitoring.ParseStatus(existing.GetAnnotations()).InvalidAnnotations
+	for _, msg := range invalid {
+		if !slices.Contains(reported, msg) {
+			r.event(owner, corev1.EventTypeWarning, "InvalidAnnotation", "%s", msg)
+		}
+	}
+	return nil
+}
+
+// createOrPatch creates the desired object owned by owner or patches the existing one if it
+// differs and reports whether it did. Both are recorded as Event on owner with the detail.
+func (r *sourceReconciler) createOrPatch(ctx context.Context, owner, desired client.Object, detail string) (bool, error) {
+	logger := log.FromContext(ctx)
+	if err := controllerutil.SetControllerReference(owner, desired, r.Scheme); err != nil {
+		return false, err
+	}
+	kind := outputKind(desired)
+
+	existing := newOutput(desired)
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+
+	if err != nil && errors.IsNotFound(err) {
+		// Object does not exist Create it
+		err = r.Create(ctx, desired)
+		if err != nil {
+			return false, err
+		}
+		logger.Info(kind+" created", "name", desired.GetName())
+		generatedObjects.WithLabelValues(kind, "created").Inc()
+		r.event(owner, corev1.EventTypeNormal, reasonCreated, "%s %s created %s", kind, desired.GetName(), detail)
+		return true, nil
+	} else if err == nil {
+		// Compare existing object with desired state to avoid unnecessary updates
+		if !outputEqual(existing, desired) || !metav1.IsControlledBy(existing, owner) {
+			patch := client.MergeFrom(existing.DeepCopyObject().(client.Object))
+			copyOutput(existing, desired)
+			if err := controllerutil.SetControllerReference(owner, existing, r.Scheme); err != nil {
+				return false, err
+			}
+			err = r.Patch(ctx, existing, patch)
+			if err != nil {
+				return false, err
+			}
+			logger.Info(kind+" updated", "name", desired.GetName())
+			generatedObjects.WithLabelValues(kind, "updated").Inc()
+			r.event(owner, corev1.EventTypeNormal, reasonUpdated, "%s %s updated %s", kind, desired.GetName(), detail)
+			return true, nil
+		} else {
+			logger.Info(kind+" unchanged", "name", desired.GetName())
+		}
+	} else {
+		return false, err
+	}
+	return false, nil
+}
+
+// failed records a failed reconcile of the Source as Event on owner and as last error in the
+// status annotation of its existing objects. Errors while recording are only logged.
+func (r *sourceReconciler) failed(ctx context.Context, src *monitoring.Source, owner client.Object, reconcileErr error) {
+	logger := log.FromContext(ctx)
+	r.event(owner, corev1.EventTypeWarning, reasonFailed, "%v", reconcileErr)
+
+	objs, err := r.listOutputs(ctx, src.Kind, src.Namespace, src.Name)
+	if err != nil {
+		logger.Error(err, "unable to record the last error", "kind", src.Kind, "name", src.Name, "namespace", src.Namespace)
+		return
+	}
+	for _, obj := range objs {
+		status := monitoring.ParseStatus(obj.GetAnnotations())
+		if status.LastError == reconcileErr.Error() {
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		status.LastError = reconcileErr.Error()
+		setStatus(obj, status.String())
+		if err := r.Patch(ctx, obj, patch); err != nil {
+			logger.Error(err, "unable to record the last error", "kind", outputKind(obj), "name", obj.GetName(), "namespace", obj.GetNamespace())
+		}
+	}
+}
+
+// event records an Event on the Source owner, if there is a Recorder.
+func (r *sourceReconciler) event(owner client.Object, eventType, reason, note string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(owner, nil, eventType, reason, "Reconcile", note, args...)
+}
+
+// deleteOutputs deletes all managed objects of every output kind generated for the Source of
+// the kind except those in keep. Lookup happens by label, so it does not depend on the naming
+// pattern.
+func (r *sourceReconciler) deleteOutputs(ctx context.Context, srcKind, namespace, srcName string, keep ...client.Object) error {
+	_, err := r.removeOutputs(ctx, srcKind, namespace, srcName, keep...)
+	return err
+}
+
+// exclude deletes the generated objects of a Source excluded for the reason. Only an exclusion
+// that deleted objects is recorded as Event on owner and counted, so repeated reconciles do not
+// repeat it.
+func (r *sourceReconciler) exclude(ctx context.Context, src *monitoring.Source, owner client.Object, reason, note string, args ...interface{}) error {
+	deleted, err := r.removeOutputs(ctx, src.Kind, src.Namespace, src.Name)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		r.event(owner, corev1.EventTypeNormal, reasonExcluded, note, args...)
+		excludedSources.WithLabelValues(src.Kind, reason).Inc()
+	}
+	return nil
+}
+
+// removeOutputs is deleteOutputs, it returns the number of deleted objects.
+func (r *sourceReconciler) removeOutputs(ctx context.Context, srcKind, namespace, srcName string, keep ...client.Object) (int, error) {
+	logger := log.FromContext(ctx)
+
+	objs, err := r.listOutputs(ctx, srcKind, namespace, srcName)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, obj := range objs {
+		if r.isKept(obj, keep) {
+			continue
+		}
+		if err := r.Delete(ctx, obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		logger.Info(outputKind(obj)+" deleted", "name", obj.GetName(), "namespace", obj.GetNamespace())
+		generatedObjects.WithLabelValues(outputKind(obj), "deleted").Inc()
+		deleted++
+	}
+	return deleted, nil
+}
+
+// isKept reports whether obj is one of keep.
+func (r *sourceReconciler) isKept(obj client.Object, keep []client.Object) bool {
+	for _, k := range keep {
+		if outputKind(obj) == outputKind(k) && obj.GetName() == k.GetName() {
+			return true
+		}
+	}
+	return false
+}
+
+// listOutputs returns the managed objects of every installed output kind generated for the
+// Source of the kind.
+func (r *sourceReconciler) listOutputs(ctx context.Context, srcKind, namespace, srcName string) ([]client.Object, error) {
+	var objs []client.Object
+	for _, list := range outputLists() {
+		err := r.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{
+			monitoring.ManagedByLabel: monitoring.ManagedByValue,
+			monitoring.ForLabel:       srcName,
+		})
+		if meta.IsNoMatchError(err) {
+			// CRD of this output kind is not installed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			if !isForKind(obj, srcKind) {
+				// generated for a Source of another kind with the same name
+				continue
+			}
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// isForKind reports whether the generated object belongs to a Source of the kind. Objects
+// without the for-kind label belong to a ServiceEntry.
+func isForKind(obj client.Object, srcKind string) bool {
+	forKind, ok := obj.GetLabels()[monitoring.ForKindLabel]
+	if !ok {
+		return srcKind == monitoring.KindServiceEntry
+	}
+	return forKind == srcKind
+}
+
+// newSourceObject returns an empty object of a Source kind other than ServiceEntry, nil if the
+// kind is unknown.
+func newSourceObject(srcKind string) client.Object {
+	switch srcKind {
+	case monitoring.KindService:
+		return &corev1.Service{}
+	case monitoring.KindIngress:
+		return &networkingv1.Ingress{}
+	case monitoring.KindHTTPRoute:
+		return &gatewayv1.HTTPRoute{}
+	}
+	return nil
+}
+
+// probePolicies returns the ProbePolicies of the namespace, none if the CRD is not installed.
+func (r *sourceReconciler) probePolicies(ctx context.Context, namespace string) ([]blackboxv1alpha1.ProbePolicy, error) {
+	var policyList blackboxv1alpha1.ProbePolicyList
+	err := r.List(ctx, &policyList, client.InNamespace(namespace))
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	return policyList.Items, err
+}
+
+// sourceRequests lists the objects a reconciler of Sources reconciles, all of them if
+// namespace is empty.
+type sourceRequests func(ctx context.Context, namespace string) []reconcile.Request
+
+// sourceController builds a controller for objects of a Source kind. The generated objects of
+// every installed output kind are owned, Namespace label changes, ProbePolicies of the namespace
+// and config changes reconcile the objects returned by list.
+func sourceController(mgr ctrl.Manager, name string, obj client.Object, pred predicate.Predicate, configChanged <-chan event.GenericEvent, list sourceRequests) (*builder.Builder, error) {
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(obj, builder.WithPredicates(pred)).
+		Owns(&monitoringv1.ServiceMonitor{})
+	for _, obj := range []client.Object{&monitoringv1.Probe{}, &monitoringv1alpha1.ScrapeConfig{}, &monitoringv1.PrometheusRule{}} {
+		installed, err := isInstalled(mgr, obj)
+		if err != nil {
+			return nil, err
+		}
+		if installed {
+			b = b.Owns(obj)
+		}
+	}
+	// a label change can move a namespace in or out of the selection
+	b = b.Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, ns client.Object) []reconcile.Request {
+		return list(ctx, ns.GetName())
+	}), builder.WithPredicates(predicate.LabelChangedPredicate{}))
+	installed, err := isInstalled(mgr, &blackboxv1alpha1.ProbePolicy{})
+	if err != nil {
+		return nil, err
+	}
+	if installed {
+		b = b.Watches(&blackboxv1alpha1.ProbePolicy{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return list(ctx, obj.GetNamespace())
+		}), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	if configChanged != nil {
+		b = b.WatchesRawSource(source.Channel(configChanged, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+			return list(ctx, "")
+		})))
+	}
+	return b, nil
+}
+
+// rulesNotInstalledOnce logs that the PrometheusRule CRD is not installed once for all
+// reconcilers of Sources.
+var rulesNotInstalledOnce sync.Once
+
+// rulesMissing reports whether the PrometheusRule CRD is not installed, so the alerts of Sources
+// are skipped.
+func rulesMissing(mgr ctrl.Manager) (bool, error) {
+	installed, err := isInstalled(mgr, &monitoringv1.PrometheusRule{})
+	if err != nil {
+		return false, err
+	}
+	if !installed {
+		rulesNotInstalledOnce.Do(func() {
+			mgr.GetLogger().Info("PrometheusRule CRD not installed, alerts are not generated")
+		})
+	}
+	return !installed, nil
+}
+
+// sourcePredicate filters events of objects that are excluded by the current config or not
+// accepted, accept may be nil. Updates pass if the old or the new object passes, so the
+// monitoring objects are deleted when a change excludes an object. A config change reconciles
+// all objects regardless of the predicate.
+func sourcePredicate(store *config.Store, accept func(client.Object) bool) predicate.Funcs {
+	selected := func(obj client.Object) bool {
+		if accept != nil && !accept(obj) {
+			return false
+		}
+		return !monitoring.NewExcluded(store.Get()).IsExcluded(obj.GetLabels())
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return selected(e.Object) },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return selected(e.ObjectOld) || selected(e.ObjectNew)
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return selected(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return selected(e.Object) },
+	}
+}
+
+// requestsFor returns requests for the objects.
+func requestsFor[T client.Object](objects []T) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(objects))
+	for _, obj := range objects {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	}
+	return requests
+}
@@ -2,32 +2,370 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/schmiddim/blackbox-operator/pkg/naming"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"os"
+	"regexp"
+	sigsjson "sigs.k8s.io/json"
+	sigsyaml "sigs.k8s.io/yaml"
 	yaml "sigs.k8s.io/yaml/goyaml.v3"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 )
 
+const (
+	// OutputServiceMonitor generates a ServiceMonitor that scrapes the blackbox exporter Service.
+	OutputServiceMonitor = "serviceMonitor"
+	// OutputProbe generates a Probe that uses the blackbox exporter as prober.
+	OutputProbe = "probe"
+	// OutputScrapeConfig generates a ScrapeConfig with static targets that are relabeled to the blackbox exporter.
+	OutputScrapeConfig = "scrapeConfig"
+)
+
+// ProberConfig describes how to reach the blackbox exporter, used by the Probe and ScrapeConfig outputs.
+type ProberConfig struct {
+	URL    string `json:"url"`
+	Scheme string `json:"scheme,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// HostMapping rewrites the probed host of hosts matching ReplacePattern on Port.
+// ReplaceWith is the replacement for the match and may use capture groups like $1. In the
+// compatibility mode, a ReplaceWith with a * and without a $, the * stands for the rest of
+// the host after the match. A path may follow the host in ReplaceWith, e.g. $1.example.com/healthz.
+type HostMapping struct {
+	Port           uint32 `json:"port,omitempty"`
+	ReplacePattern string `json:"replacePattern"`
+	ReplaceWith    string `json:"replaceWith"`
+	// Scheme of the probed URL, http or https. By default https is used for HTTPS ports.
+	Scheme string `json:"scheme,omitempty"`
+	// TargetPort is the probed port, Port by default.
+	TargetPort uint32 `json:"targetPort,omitempty"`
+	// Path of the probed URL, it replaces a path from ReplaceWith.
+	Path string `json:"path,omitempty"`
+
+	replaceRegexp *regexp.Regexp
+}
+
+// ReplaceRegexp returns the compiled ReplacePattern or nil if it is invalid. Patterns are
+// compiled by ParseConfig, for mappings created otherwise it is compiled on every call.
+func (hm *HostMapping) ReplaceRegexp() *regexp.Regexp {
+	if hm.replaceRegexp != nil {
+		return hm.replaceRegexp
+	}
+	re, _ := regexp.Compile(hm.ReplacePattern)
+	return re
+}
+
+// IsCompatibilityMode reports whether the * syntax is used instead of capture groups.
+func (hm *HostMapping) IsCompatibilityMode() bool {
+	return strings.Contains(hm.ReplaceWith, "*") && !strings.Contains(hm.ReplaceWith, "$")
+}
+
+// ModuleMapping selects the blackbox module for hosts matching MatchPattern on Port.
+type ModuleMapping struct {
+	Port          uint32 `json:"port,omitempty"`
+	MatchPattern  string `json:"matchPattern"`
+	ReplaceModule string `json:"replaceModule"`
+
+	matchRegexp *regexp.Regexp
+}
+
+// MatchRegexp returns the compiled MatchPattern or nil if it is invalid. Patterns are
+// compiled by ParseConfig, for mappings created otherwise it is compiled on every call.
+func (mm *ModuleMapping) MatchRegexp() *regexp.Regexp {
+	if mm.matchRegexp != nil {
+		return mm.matchRegexp
+	}
+	re, _ := regexp.Compile(mm.MatchPattern)
+	return re
+}
+
+// ModuleRule selects Module for targets matching all of its conditions, empty conditions match
+// every target. Rules are evaluated in order, the first match wins. The Name of the rule is
+// recorded as label module_rule on the target.
+type ModuleRule struct {
+	Name   string `json:"name"`
+	Module string `json:"module"`
+	// HostPattern is a regular expression matched against the host.
+	HostPattern string `json:"hostPattern,omitempty"`
+	// Ports are port numbers or ranges like 8000-8999.
+	Ports     []string `json:"ports,omitempty"`
+	PortNames []string `json:"portNames,omitempty"`
+	// Protocols are compared case-insensitively.
+	Protocols []string `json:"protocols,omitempty"`
+	// Location of the ServiceEntry, MESH_EXTERNAL or MESH_INTERNAL.
+	Location string `json:"location,omitempty"`
+	// Resolution of the ServiceEntry, e.g. DNS.
+	Resolution string `json:"resolution,omitempty"`
+	// Selector matches the labels of the ServiceEntry.
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+
+	hostRegexp *regexp.Regexp
+}
+
+// HostRegexp returns the compiled HostPattern, nil if it is empty or invalid. Patterns are
+// compiled by ParseConfig, for rules created otherwise it is compiled on every call.
+func (mr *ModuleRule) HostRegexp() *regexp.Regexp {
+	if mr.hostRegexp != nil || mr.HostPattern == "" {
+		return mr.hostRegexp
+	}
+	re, _ := regexp.Compile(mr.HostPattern)
+	return re
+}
+
+// MatchesPort reports whether the port number is in one of the Ports, true if there are none.
+func (mr *ModuleRule) MatchesPort(number uint32) bool {
+	if len(mr.Ports) == 0 {
+		return true
+	}
+	for _, ports := range mr.Ports {
+		low, high, err := ParsePortRange(ports)
+		if err == nil && low <= number && number <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePortRange parses a port number or a range like 8000-8999.
+func ParsePortRange(ports string) (uint32, uint32, error) {
+	lowText, highText, isRange := strings.Cut(ports, "-")
+	low, err := strconv.ParseUint(strings.TrimSpace(lowText), 10, 16)
+	if err != nil || low == 0 {
+		return 0, 0, fmt.Errorf("must be a port number or a range like 8000-8999")
+	}
+	if !isRange {
+		return uint32(low), uint32(low), nil
+	}
+	high, err := strconv.ParseUint(strings.TrimSpace(highText), 10, 16)
+	if err != nil || high < low {
+		return 0, 0, fmt.Errorf("must be a port number or a range like 8000-8999")
+	}
+	return uint32(low), uint32(high), nil
+}
+
+// AlertsConfig generates a PrometheusRule with alerts for the targets of every Source.
+type AlertsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Severity of the alerts unless a Source or a template sets one, warning by default.
+	Severity string `json:"severity,omitempty"`
+	// SeverityLabel is a label of the Source that sets the severity of its alerts.
+	SeverityLabel string `json:"severityLabel,omitempty"`
+	// CertExpiryThreshold is the remaining validity of a certificate below which the certificate
+	// expiry alert fires, 14 days by default.
+	CertExpiryThreshold monitoringv1.Duration `json:"certExpiryThreshold,omitempty"`
+	// RuleLabels are set on the PrometheusRules, e.g. to match the ruleSelector of Prometheus.
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+	// Templates of the alerts, the ProbeFailed and CertificateExpiry alerts by default.
+	Templates []AlertTemplate `json:"templates,omitempty"`
+}
+
+// AlertTemplate is an alerting rule generated for a Source. Expr is a Go template with the
+// fields .Name, .Namespace and .Kind of the Source, .Selector and .HTTPSSelector matching the
+// series of all and of the https targets and .CertExpiryThreshold in seconds. Labels and
+// Annotations are passed to Prometheus unchanged, so they may use its templates like
+// {{ $labels.instance }}.
+type AlertTemplate struct {
+	Alert string                `json:"alert"`
+	Expr  string                `json:"expr"`
+	For   monitoringv1.Duration `json:"for,omitempty"`
+	// HTTPSOnly generates the alert only for Sources with https targets.
+	HTTPSOnly   bool              `json:"httpsOnly,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	exprTemplate *template.Template
+}
+
+// AlertExprData are the fields of the Expr of an AlertTemplate.
+type AlertExprData struct {
+	Name      string
+	Namespace string
+	Kind      string
+	// Selector matches the series of all targets of the Source, without braces
+	Selector string
+	// HTTPSSelector matches the series of the https targets of the Source, without braces
+	HTTPSSelector string
+	// CertExpiryThreshold of the AlertsConfig in seconds
+	CertExpiryThreshold int64
+}
+
+// DefaultAlertTemplates are used if AlertsConfig.Templates is empty.
+var DefaultAlertTemplates = []AlertTemplate{
+	{
+		Alert: "ProbeFailed",
+		Expr:  "probe_success{ {{ .Selector }} } == 0",
+		For:   "5m",
+		Annotations: map[string]string{
+			"summary":     "Probe of {{ $labels.instance }} failed",
+			"description": "The blackbox exporter probe of {{ $labels.instance }} for {{ $labels.for }} fails.",
+		},
+	},
+	{
+		Alert:     "CertificateExpiry",
+		Expr:      "probe_ssl_earliest_cert_expiry{ {{ .HTTPSSelector }} } - time() < {{ .CertExpiryThreshold }}",
+		For:       "1h",
+		HTTPSOnly: true,
+		Annotations: map[string]string{
+			"summary":     "Certificate of {{ $labels.instance }} expires soon",
+			"description": "The certificate of {{ $labels.instance }} for {{ $labels.for }} expires in {{ $value | humanizeDuration }}.",
+		},
+	},
+}
+
+func init() {
+	for i := range DefaultAlertTemplates {
+		at := &DefaultAlertTemplates[i]
+		at.exprTemplate = template.Must(parseExpr(at.Alert, at.Expr))
+	}
+}
+
+// AlertTemplates returns the Templates, the DefaultAlertTemplates if there are none.
+func (a *AlertsConfig) AlertTemplates() []AlertTemplate {
+	if len(a.Templates) == 0 {
+		return DefaultAlertTemplates
+	}
+	return a.Templates
+}
+
+// AlertSeverity returns the Severity, warning if it is not set.
+func (a *AlertsConfig) AlertSeverity() string {
+	if a.Severity == "" {
+		return "warning"
+	}
+	return a.Severity
+}
+
+// CertExpiry returns the CertExpiryThreshold, 14 days if it is not set or invalid.
+func (a *AlertsConfig) CertExpiry() time.Duration {
+	d, err := model.ParseDuration(string(a.CertExpiryThreshold))
+	if err != nil || d <= 0 {
+		return 14 * 24 * time.Hour
+	}
+	return time.Duration(d)
+}
+
+// ExprTemplate returns the parsed Expr or nil if it is invalid. Templates are parsed by
+// ParseConfig and the DefaultAlertTemplates at init, for templates created otherwise it is
+// parsed on every call.
+func (at *AlertTemplate) ExprTemplate() *template.Template {
+	if at.exprTemplate != nil {
+		return at.exprTemplate
+	}
+	tmpl, _ := parseExpr(at.Alert, at.Expr)
+	return tmpl
+}
+
+func parseExpr(alert, expr string) (*template.Template, error) {
+	return template.New(alert).Option("missingkey=error").Parse(expr)
+}
+
+// Probers of the blackbox exporter that a Module may use.
+var Probers = []string{"http", "tcp", "icmp", "dns", "grpc"}
+
+// ExporterConfig declares the modules of the blackbox exporter. If set, the operator writes
+// them to ConfigMap, reloads the exporter via ReloadURL and only accepts declared modules.
+type ExporterConfig struct {
+	ConfigMap ExporterConfigMap `json:"configMap,omitempty"`
+	// ReloadURL is the reload endpoint of the exporter, e.g.
+	// http://blackbox-exporter.monitoring.svc:9115/-/reload. No reload if empty. With the DNS
+	// name of a Service every ready endpoint of the Service is reloaded.
+	ReloadURL string `json:"reloadURL,omitempty"`
+	// ReloadDelay is the time between an update of the ConfigMap and the reload, so the
+	// kubelet can update the mounted file. 1m by default.
+	ReloadDelay monitoringv1.Duration `json:"reloadDelay,omitempty"`
+	Modules     map[string]Module     `json:"modules,omitempty"`
+}
+
+// ExporterConfigMap is the ConfigMap with the config file of the blackbox exporter.
+type ExporterConfigMap struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Key of the config file, blackbox.yaml by default.
+	Key string `json:"key,omitempty"`
+}
+
+// Module is a module of the blackbox exporter. The settings of the probers are passed to the
+// exporter as they are.
+type Module struct {
+	Prober  string                 `json:"prober"`
+	Timeout monitoringv1.Duration  `json:"timeout,omitempty"`
+	HTTP    map[string]interface{} `json:"http,omitempty"`
+	TCP     map[string]interface{} `json:"tcp,omitempty"`
+	ICMP    map[string]interface{} `json:"icmp,omitempty"`
+	DNS     map[string]interface{} `json:"dns,omitempty"`
+	GRPC    map[string]interface{} `json:"grpc,omitempty"`
+}
+
+// IsManaged reports whether the operator manages the config of the exporter.
+func (e *ExporterConfig) IsManaged() bool {
+	return len(e.Modules) > 0
+}
+
+// HasModule reports whether the module is declared, true for every module if the config of
+// the exporter is not managed.
+func (e *ExporterConfig) HasModule(name string) bool {
+	if !e.IsManaged() {
+		return true
+	}
+	_, ok := e.Modules[name]
+	return ok
+}
+
+// ConfigMapKey returns the Key, blackbox.yaml if it is not set.
+func (e *ExporterConfig) ConfigMapKey() string {
+	if e.ConfigMap.Key == "" {
+		return "blackbox.yaml"
+	}
+	return e.ConfigMap.Key
+}
+
+// Delay returns the ReloadDelay, 1m if it is not set.
+func (e *ExporterConfig) Delay() time.Duration {
+	d, err := model.ParseDuration(string(e.ReloadDelay))
+	if err != nil || e.ReloadDelay == "" {
+		return time.Minute
+	}
+	return time.Duration(d)
+}
+
+// Render returns the config file of the exporter with the Modules.
+func (e *ExporterConfig) Render() (string, error) {
+	data, err := sigsyaml.Marshal(map[string]interface{}{"modules": e.Modules})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 type Config struct {
 	LogLevel                    string                `json:"logLevel"`
 	DefaultModule               string                `json:"defaultModule"`
 	ServiceMonitorNamingPattern string                `json:"serviceMonitorNamingPattern"`
 	Interval                    monitoringv1.Duration `json:"interval"`
 	ScrapeTimeout               monitoringv1.Duration `json:"scrapeTimeout"`
-	HostMappings                []struct {
-		Port           uint32 `yaml:"port,omitempty"`
-		ReplacePattern string `yaml:"replacePattern"`
-		ReplaceWith    string `yaml:"replaceWith"`
-	} `json:"hostMappings,omitempty"`
-	ModuleMappings []struct {
-		Port          uint32 `yaml:"port,omitempty"`
-		MatchPattern  string `yaml:"matchPattern"`
-		ReplaceModule string `yaml:"replaceModule"`
-	} `json:"moduleMappings,omitempty"`
-	LabelSelector          metav1.LabelSelector `json:"selector"`
-	ExcludeSelector        metav1.LabelSelector `json:"exclude,omitempty"`
-	ProtocolModuleMappings map[string]string    `json:"protocolModuleMappings,omitempty"`
+	HostMappings                []HostMapping         `json:"hostMappings,omitempty"`
+	ModuleMappings              []ModuleMapping       `json:"moduleMappings,omitempty"`
+	ModuleRules                 []ModuleRule          `json:"moduleRules,omitempty"`
+	LabelSelector               metav1.LabelSelector  `json:"selector"`
+	IncludeSelector             metav1.LabelSelector  `json:"include,omitempty"`
+	NamespaceSelector           metav1.LabelSelector  `json:"namespaceSelector,omitempty"`
+	Namespaces                  []string              `json:"namespaces,omitempty"`
+	ExcludedNamespaces          []string              `json:"excludedNamespaces,omitempty"`
+	ExcludeSelector             metav1.LabelSelector  `json:"exclude,omitempty"`
+	ProtocolModuleMappings      map[string]string     `json:"protocolModuleMappings,omitempty"`
+	Output                      string                `json:"output,omitempty"`
+	Prober                      ProberConfig          `json:"prober,omitempty"`
+	Alerts                      AlertsConfig          `json:"alerts,omitempty"`
+	Exporter                    ExporterConfig        `json:"exporter,omitempty"`
 }
 
 func LoadConfig(filePath string) (*Config, error) {
@@ -36,6 +374,29 @@ func LoadConfig(filePath string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
+	return ParseConfig(data)
+}
+
+// defaultScrapeTimeout returns 30s, capped at the interval like in Prometheus.
+func defaultScrapeTimeout(interval monitoringv1.Duration) monitoringv1.Duration {
+	if d, err := model.ParseDuration(string(interval)); err == nil && d > 0 && time.Duration(d) < 30*time.Second {
+		return interval
+	}
+	return "30s"
+}
+
+// Validate checks a Config that was not parsed, e.g. one merged from several sources. It
+// compiles the patterns of the mappings, so c must not be shared with other goroutines.
+func (c *Config) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errs.ToAggregate())
+	}
+	return nil
+}
+
+// ParseConfig parses and validates the content of a config file. Unknown fields are rejected,
+// errors point at the offending field, e.g. hostMappings[1].replacePattern.
+func ParseConfig(data []byte) (*Config, error) {
 	var jsonData interface{}
 	if err := yaml.Unmarshal(data, &jsonData); err != nil {
 		return nil, err
@@ -49,13 +410,31 @@ func LoadConfig(filePath string) (*Config, error) {
 	// Default Values
 	config.DefaultModule = "http_2xx"
 	config.LogLevel = "info"
-	config.ScrapeTimeout = "30s"
 	config.Interval = "30s"
+	config.ServiceMonitorNamingPattern = naming.DefaultPattern
+	config.Output = OutputServiceMonitor
+	config.Prober.Scheme = "http"
+	config.Prober.Path = "/probe"
 
-	err = json.Unmarshal(result, &config)
-
+	strictErrs, err := sigsjson.UnmarshalStrict(result, &config)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	var errs field.ErrorList
+	for _, strictErr := range strictErrs {
+		var fieldErr sigsjson.FieldError
+		if errors.As(strictErr, &fieldErr) {
+			errs = append(errs, &field.Error{Type: field.ErrorTypeForbidden, Field: fieldErr.FieldPath(), Detail: "unknown field"})
+			continue
+		}
+		errs = append(errs, field.InternalError(nil, strictErr))
+	}
+	if config.ScrapeTimeout == "" {
+		config.ScrapeTimeout = defaultScrapeTimeout(config.Interval)
+	}
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config: %w", errs.ToAggregate())
 	}
 	return &config, nil
 }
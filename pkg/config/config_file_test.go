@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -56,21 +59,18 @@ func TestLoadConfig(t *testing.T) {
 	expectedConfig := &Config{
 		LogLevel:                    "debug",
 		DefaultModule:               "http_test",
-		ServiceMonitorNamingPattern: "sm-%",
+		ServiceMonitorNamingPattern: "sm-%s",
 		Interval:                    monitoringv1.Duration("10s"),
 		ScrapeTimeout:               monitoringv1.Duration("5s"),
 		LabelSelector: metav1.LabelSelector{
 			MatchLabels: map[string]string{"app.kubernetes.io/name": "test-app"},
 		},
-		HostMappings: []struct {
-			Port           uint32 `yaml:"port,omitempty"`
-			ReplacePattern string `yaml:"replacePattern"`
-			ReplaceWith    string `yaml:"replaceWith"`
-		}{
+		HostMappings: []HostMapping{
 			{
 				Port:           443,
 				ReplacePattern: "www.ebay.",
 				ReplaceWith:    "www.ebay.*/health",
+				replaceRegexp:  regexp.MustCompile("www.ebay."),
 			},
 		},
 		ProtocolModuleMappings: map[string]string{"TCP": "tcp_connect"},
@@ -117,7 +117,7 @@ func TestExcludeSelector(t *testing.T) {
 func TestLoadConfig_Defaults(t *testing.T) {
 	const yamlWithoutSelector = `
 logLevel: "warn"
-module: "http_default"
+defaultModule: "http_default"
 interval: "20s"
 scrapeTimeout: "15s"
 `
@@ -136,3 +136,218 @@ scrapeTimeout: "15s"
 		t.Errorf("Expected no MatchExpressions, got: %v", config.LabelSelector.MatchExpressions)
 	}
 }
+
+func TestLoadConfig_InvalidNamingPattern(t *testing.T) {
+	filePath := createTempFile(t, `serviceMonitorNamingPattern: "sm-%"`)
+	defer os.Remove(filePath)
+
+	_, err := LoadConfig(filePath)
+	if err == nil {
+		t.Fatalf("Naming pattern should be invalid")
+	}
+}
+
+func TestLoadConfig_Output(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "default", content: `logLevel: info`},
+		{name: "probe", content: "output: probe\nprober:\n  url: blackbox:9115"},
+		{name: "probe without url", content: `output: probe`, wantErr: true},
+		{name: "unknown output", content: `output: podMonitor`, wantErr: true},
+	}
+	for _, tt := range tests {
+		filePath := createTempFile(t, tt.content)
+		_, err := LoadConfig(filePath)
+		os.Remove(filePath)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got '%v'", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+const exporterConfig = `exporter:
+  configMap:
+    name: blackbox-exporter
+    namespace: monitoring
+  reloadURL: http://blackbox-exporter.monitoring.svc:9115/-/reload
+  modules:
+    http_2xx:
+      prober: http
+      timeout: 5s
+    tcp_connect:
+      prober: tcp
+`
+
+func TestParseConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "valid", content: "interval: 1m\nscrapeTimeout: 30s"},
+		{name: "default timeout capped at interval", content: "interval: 10s"},
+		{name: "unknown field", content: `modules: http_2xx`, wantErr: `modules: Forbidden: unknown field`},
+		{
+			name:    "unknown nested field",
+			content: "hostMappings:\n  - port: 443\n    replacePattern: a\n    replaceWith: b\n  - port: 443\n    replacePatern: a\n    replaceWith: b",
+			wantErr: `hostMappings[1].replacePatern: Forbidden: unknown field`,
+		},
+		{
+			name:    "invalid regex",
+			content: "hostMappings:\n  - port: 443\n    replacePattern: a\n    replaceWith: b\n  - port: 443\n    replacePattern: \"(\"\n    replaceWith: b",
+			wantErr: `hostMappings[1].replacePattern: Invalid value: "("`,
+		},
+		{
+			name:    "invalid module regex",
+			content: "moduleMappings:\n  - port: 443\n    matchPattern: \"[a\"\n    replaceModule: tcp_connect",
+			wantErr: `moduleMappings[0].matchPattern: Invalid value: "[a"`,
+		},
+		{
+			name:    "invalid host mapping scheme",
+			content: "hostMappings:\n  - port: 443\n    replacePattern: a\n    replaceWith: b\n    scheme: ftp",
+			wantErr: `hostMappings[0].scheme: Unsupported value: "ftp"`,
+		},
+		{
+			name:    "invalid host mapping target port",
+			content: "hostMappings:\n  - port: 443\n    replacePattern: a\n    replaceWith: b\n    targetPort: 70000",
+			wantErr: `hostMappings[0].targetPort: Invalid value: 70000`,
+		},
+		{
+			name:    "relative host mapping path",
+			content: "hostMappings:\n  - port: 443\n    replacePattern: a\n    replaceWith: b\n    path: healthz",
+			wantErr: `hostMappings[0].path: Invalid value: "healthz": must start with /`,
+		},
+		{
+			name:    "duplicate module rule",
+			content: "moduleRules:\n  - name: a\n    module: tcp_connect\n  - name: a\n    module: http_2xx",
+			wantErr: `moduleRules[1].name: Duplicate value: "a"`,
+		},
+		{
+			name:    "invalid module rule port range",
+			content: "moduleRules:\n  - name: a\n    module: tcp_connect\n    ports: [\"9000-8000\"]",
+			wantErr: `moduleRules[0].ports[0]: Invalid value: "9000-8000"`,
+		},
+		{
+			name:    "invalid module rule location",
+			content: "moduleRules:\n  - name: a\n    module: tcp_connect\n    location: OUTSIDE",
+			wantErr: `moduleRules[0].location: Unsupported value: "OUTSIDE"`,
+		},
+		{
+			name:    "missing port",
+			content: "moduleMappings:\n  - matchPattern: a\n    replaceModule: tcp_connect",
+			wantErr: `moduleMappings[0].port: Required value`,
+		},
+		{name: "invalid interval", content: `interval: 10 seconds`, wantErr: `interval: Invalid value: "10 seconds"`},
+		{name: "timeout above interval", content: "interval: 10s\nscrapeTimeout: 1m", wantErr: `scrapeTimeout: Invalid value: "1m": must not be greater than interval 10s`},
+		{
+			name:    "invalid selector",
+			content: "exclude:\n  matchExpressions:\n    - key: team\n      operator: Unknown",
+			wantErr: `exclude.matchExpressions[0].operator: Invalid value: "Unknown"`,
+		},
+		{name: "invalid naming pattern", content: `serviceMonitorNamingPattern: "sm-%"`, wantErr: `serviceMonitorNamingPattern: Invalid value`},
+		{
+			name:    "alert templates",
+			content: "alerts:\n  enabled: true\n  templates:\n    - alert: ProbeSlow\n      expr: probe_duration_seconds{ {{ .Selector }} } > 5\n      for: 10m",
+		},
+		{
+			name:    "unknown field in alert expr",
+			content: "alerts:\n  enabled: true\n  templates:\n    - alert: ProbeSlow\n      expr: probe_duration_seconds{ {{ .Targets }} } > 5",
+			wantErr: `alerts.templates[0].expr: Invalid value`,
+		},
+		{
+			name:    "duplicate alert",
+			content: "alerts:\n  templates:\n    - alert: ProbeSlow\n      expr: up\n    - alert: ProbeSlow\n      expr: up",
+			wantErr: `alerts.templates[1].alert: Duplicate value: "ProbeSlow"`,
+		},
+		{name: "invalid cert expiry threshold", content: "alerts:\n  certExpiryThreshold: 2 weeks", wantErr: `alerts.certExpiryThreshold: Invalid value: "2 weeks"`},
+		{
+			name:    "exporter modules",
+			content: exporterConfig + "moduleRules:\n  - name: tcp\n    module: tcp_connect\n    ports: [\"5432\"]",
+		},
+		{
+			name:    "undeclared default module",
+			content: strings.Replace(exporterConfig, "http_2xx:", "http_200:", 1),
+			wantErr: `defaultModule: Not found: "http_2xx"`,
+		},
+		{
+			name:    "undeclared module of a mapping",
+			content: exporterConfig + "moduleMappings:\n  - matchPattern: db\n    replaceModule: tcp",
+			wantErr: `moduleMappings[0].replaceModule: Not found: "tcp"`,
+		},
+		{
+			name:    "undeclared module of a protocol",
+			content: exporterConfig + "protocolModuleMappings:\n  TCP: tcp",
+			wantErr: `protocolModuleMappings[TCP]: Not found: "tcp"`,
+		},
+		{
+			name:    "unsupported prober",
+			content: strings.Replace(exporterConfig, "prober: tcp", "prober: udp", 1),
+			wantErr: `exporter.modules[tcp_connect].prober: Unsupported value: "udp"`,
+		},
+		{
+			name:    "invalid reload URL",
+			content: strings.Replace(exporterConfig, "http://blackbox-exporter.monitoring.svc:9115/-/reload", "blackbox-exporter:9115", 1),
+			wantErr: `exporter.reloadURL: Invalid value: "blackbox-exporter:9115"`,
+		},
+		{
+			name:    "config map without modules",
+			content: "exporter:\n  configMap:\n    name: blackbox-exporter\n    namespace: monitoring",
+			wantErr: `exporter.modules: Required value`,
+		},
+	}
+	for _, tt := range tests {
+		cfg, err := ParseConfig([]byte(tt.content))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error '%v'", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected error containing '%s', got config %v", tt.name, tt.wantErr, cfg)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing '%s', got '%v'", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestExporterConfig_Render(t *testing.T) {
+	cfg, err := ParseConfig([]byte(exporterConfig + `    http_post:
+      prober: http
+      http:
+        method: POST
+        valid_status_codes: [200, 201]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cfg.Exporter.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `modules:
+  http_2xx:
+    prober: http
+    timeout: 5s
+  http_post:
+    http:
+      method: POST
+      valid_status_codes:
+      - 200
+      - 201
+    prober: http
+  tcp_connect:
+    prober: tcp
+`
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if cfg.Exporter.ConfigMapKey() != "blackbox.yaml" || cfg.Exporter.Delay() != time.Minute {
+		t.Errorf("unexpected defaults %s, %s", cfg.Exporter.ConfigMapKey(), cfg.Exporter.Delay())
+	}
+}
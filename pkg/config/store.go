@@ -0,0 +1,41 @@
+package config
+
+import "sync/atomic"
+
+// Store holds the Config currently in effect. It is safe for concurrent use and
+// allows replacing the Config atomically when the config file is reloaded.
+// An override, e.g. from a BlackboxOperatorConfig resource, takes precedence
+// over the Config from the file while it is set.
+type Store struct {
+	file     atomic.Pointer[Config]
+	override atomic.Pointer[Config]
+}
+
+func NewStore(cfg *Config) *Store {
+	s := &Store{}
+	s.Set(cfg)
+	return s
+}
+
+// Get returns the Config currently in effect. It must not be modified.
+func (s *Store) Get() *Config {
+	if cfg := s.override.Load(); cfg != nil {
+		return cfg
+	}
+	return s.file.Load()
+}
+
+// Set replaces the Config from the config file.
+func (s *Store) Set(cfg *Config) {
+	s.file.Store(cfg)
+}
+
+// SetOverride sets a Config that takes precedence over the config file, nil removes it.
+func (s *Store) SetOverride(cfg *Config) {
+	s.override.Store(cfg)
+}
+
+// Override returns the Config set via SetOverride or nil.
+func (s *Store) Override() *Config {
+	return s.override.Load()
+}
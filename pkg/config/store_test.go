@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestStore(t *testing.T) {
+	first := &Config{DefaultModule: "http_2xx"}
+	store := NewStore(first)
+	if store.Get() != first {
+		t.Errorf("expected initial config")
+	}
+
+	second := &Config{DefaultModule: "tcp_connect"}
+	store.Set(second)
+	if store.Get() != second {
+		t.Errorf("expected replaced config")
+	}
+}
+
+func TestStoreOverride(t *testing.T) {
+	file := &Config{DefaultModule: "http_2xx"}
+	store := NewStore(file)
+
+	override := &Config{DefaultModule: "tcp_connect"}
+	store.SetOverride(override)
+	store.Set(&Config{DefaultModule: "icmp"})
+	if store.Get() != override {
+		t.Errorf("expected override to take precedence over the config file")
+	}
+
+	store.SetOverride(nil)
+	if store.Get().DefaultModule != "icmp" {
+		t.Errorf("expected config file after removing the override, got %s", store.Get().DefaultModule)
+	}
+}
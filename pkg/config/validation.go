@@ -0,0 +1,316 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/schmiddim/blackbox-operator/pkg/naming"
+	"istio.io/api/networking/v1alpha3"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validate checks the Config and compiles the patterns of the mappings.
+func (c *Config) validate() field.ErrorList {
+	var errs field.ErrorList
+
+	if _, err := naming.Parse(c.ServiceMonitorNamingPattern); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("serviceMonitorNamingPattern"), c.ServiceMonitorNamingPattern, err.Error()))
+	}
+	if c.DefaultModule == "" {
+		errs = append(errs, field.Required(field.NewPath("defaultModule"), ""))
+	}
+
+	interval, intervalErrs := validateDuration(string(c.Interval), field.NewPath("interval"))
+	errs = append(errs, intervalErrs...)
+	scrapeTimeout, scrapeTimeoutErrs := validateDuration(string(c.ScrapeTimeout), field.NewPath("scrapeTimeout"))
+	errs = append(errs, scrapeTimeoutErrs...)
+	if len(intervalErrs) == 0 && len(scrapeTimeoutErrs) == 0 && scrapeTimeout > interval {
+		errs = append(errs, field.Invalid(field.NewPath("scrapeTimeout"), c.ScrapeTimeout, "must not be greater than interval "+string(c.Interval)))
+	}
+
+	for i := range c.HostMappings {
+		hm := &c.HostMappings[i]
+		path := field.NewPath("hostMappings").Index(i)
+		errs = append(errs, validatePort(hm.Port, path.Child("port"))...)
+		var regexpErrs field.ErrorList
+		hm.replaceRegexp, regexpErrs = compile(hm.ReplacePattern, path.Child("replacePattern"))
+		errs = append(errs, regexpErrs...)
+		if hm.ReplaceWith == "" {
+			errs = append(errs, field.Required(path.Child("replaceWith"), ""))
+		}
+		if hm.Scheme != "" && hm.Scheme != "http" && hm.Scheme != "https" {
+			errs = append(errs, field.NotSupported(path.Child("scheme"), hm.Scheme, []string{"http", "https"}))
+		}
+		if hm.TargetPort != 0 {
+			errs = append(errs, validatePort(hm.TargetPort, path.Child("targetPort"))...)
+		}
+		if hm.Path != "" && !strings.HasPrefix(hm.Path, "/") {
+			errs = append(errs, field.Invalid(path.Child("path"), hm.Path, "must start with /"))
+		}
+	}
+	for i := range c.ModuleMappings {
+		mm := &c.ModuleMappings[i]
+		path := field.NewPath("moduleMappings").Index(i)
+		errs = append(errs, validatePort(mm.Port, path.Child("port"))...)
+		var regexpErrs field.ErrorList
+		mm.matchRegexp, regexpErrs = compile(mm.MatchPattern, path.Child("matchPattern"))
+		errs = append(errs, regexpErrs...)
+		if mm.ReplaceModule == "" {
+			errs = append(errs, field.Required(path.Child("replaceModule"), ""))
+		}
+	}
+	ruleNames := map[string]bool{}
+	for i := range c.ModuleRules {
+		mr := &c.ModuleRules[i]
+		path := field.NewPath("moduleRules").Index(i)
+		switch {
+		case mr.Name == "":
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		case ruleNames[mr.Name]:
+			errs = append(errs, field.Duplicate(path.Child("name"), mr.Name))
+		default:
+			for _, msg := range validation.IsValidLabelValue(mr.Name) {
+				errs = append(errs, field.Invalid(path.Child("name"), mr.Name, msg))
+			}
+		}
+		ruleNames[mr.Name] = true
+		if mr.Module == "" {
+			errs = append(errs, field.Required(path.Child("module"), ""))
+		}
+		if mr.HostPattern != "" {
+			var regexpErrs field.ErrorList
+			mr.hostRegexp, regexpErrs = compile(mr.HostPattern, path.Child("hostPattern"))
+			errs = append(errs, regexpErrs...)
+		}
+		for j, ports := range mr.Ports {
+			if _, _, err := ParsePortRange(ports); err != nil {
+				errs = append(errs, field.Invalid(path.Child("ports").Index(j), ports, err.Error()))
+			}
+		}
+		if _, ok := v1alpha3.ServiceEntry_Location_value[mr.Location]; mr.Location != "" && !ok {
+			errs = append(errs, field.NotSupported(path.Child("location"), mr.Location, enumNames(v1alpha3.ServiceEntry_Location_value)))
+		}
+		if _, ok := v1alpha3.ServiceEntry_Resolution_value[mr.Resolution]; mr.Resolution != "" && !ok {
+			errs = append(errs, field.NotSupported(path.Child("resolution"), mr.Resolution, enumNames(v1alpha3.ServiceEntry_Resolution_value)))
+		}
+		errs = append(errs, metav1validation.ValidateLabelSelector(&mr.Selector, metav1validation.LabelSelectorValidationOptions{}, path.Child("selector"))...)
+	}
+	for protocol, module := range c.ProtocolModuleMappings {
+		if module == "" {
+			errs = append(errs, field.Required(field.NewPath("protocolModuleMappings").Key(protocol), ""))
+		}
+	}
+
+	selectorOpts := metav1validation.LabelSelectorValidationOptions{}
+	errs = append(errs, metav1validation.ValidateLabelSelector(&c.LabelSelector, selectorOpts, field.NewPath("selector"))...)
+	errs = append(errs, metav1validation.ValidateLabelSelector(&c.IncludeSelector, selectorOpts, field.NewPath("include"))...)
+	errs = append(errs, metav1validation.ValidateLabelSelector(&c.ExcludeSelector, selectorOpts, field.NewPath("exclude"))...)
+	errs = append(errs, metav1validation.ValidateLabelSelector(&c.NamespaceSelector, selectorOpts, field.NewPath("namespaceSelector"))...)
+	errs = append(errs, validateNamespaces(c.Namespaces, field.NewPath("namespaces"))...)
+	errs = append(errs, validateNamespaces(c.ExcludedNamespaces, field.NewPath("excludedNamespaces"))...)
+
+	errs = append(errs, c.Alerts.validate(field.NewPath("alerts"))...)
+	errs = append(errs, c.validateExporter()...)
+
+	switch c.Output {
+	case OutputServiceMonitor:
+	case OutputProbe, OutputScrapeConfig:
+		if c.Prober.URL == "" {
+			errs = append(errs, field.Required(field.NewPath("prober", "url"), "required for output "+c.Output))
+		}
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("output"), c.Output, []string{OutputServiceMonitor, OutputProbe, OutputScrapeConfig}))
+	}
+	return errs
+}
+
+// validateExporter checks the modules of the exporter and that every module referenced by the
+// Config is declared.
+func (c *Config) validateExporter() field.ErrorList {
+	var errs field.ErrorList
+	e := &c.Exporter
+	path := field.NewPath("exporter")
+	if !e.IsManaged() {
+		if e.ConfigMap.Name != "" || e.ReloadURL != "" {
+			errs = append(errs, field.Required(path.Child("modules"), "required to manage the config of the exporter"))
+		}
+		return errs
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(e.ConfigMap.Name) {
+		errs = append(errs, field.Invalid(path.Child("configMap", "name"), e.ConfigMap.Name, msg))
+	}
+	for _, msg := range validation.IsDNS1123Label(e.ConfigMap.Namespace) {
+		errs = append(errs, field.Invalid(path.Child("configMap", "namespace"), e.ConfigMap.Namespace, msg))
+	}
+	for _, msg := range validation.IsConfigMapKey(e.ConfigMapKey()) {
+		errs = append(errs, field.Invalid(path.Child("configMap", "key"), e.ConfigMap.Key, msg))
+	}
+	if e.ReloadURL != "" {
+		if u, err := url.Parse(e.ReloadURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, field.Invalid(path.Child("reloadURL"), e.ReloadURL, "must be an absolute http or https URL"))
+		}
+	}
+	if e.ReloadDelay != "" {
+		if _, err := model.ParseDuration(string(e.ReloadDelay)); err != nil {
+			errs = append(errs, field.Invalid(path.Child("reloadDelay"), e.ReloadDelay, err.Error()))
+		}
+	}
+
+	for _, name := range sortedKeys(e.Modules) {
+		module := e.Modules[name]
+		modulePath := path.Child("modules").Key(name)
+		if strings.ContainsAny(name, " \t\n") {
+			errs = append(errs, field.Invalid(modulePath, name, "must not contain whitespace"))
+		}
+		if !slices.Contains(Probers, module.Prober) {
+			errs = append(errs, field.NotSupported(modulePath.Child("prober"), module.Prober, Probers))
+		}
+		if module.Timeout != "" {
+			_, durationErrs := validateDuration(string(module.Timeout), modulePath.Child("timeout"))
+			errs = append(errs, durationErrs...)
+		}
+	}
+
+	// referenced modules
+	if !e.HasModule(c.DefaultModule) {
+		errs = append(errs, field.NotFound(field.NewPath("defaultModule"), c.DefaultModule))
+	}
+	for i, mm := range c.ModuleMappings {
+		if mm.ReplaceModule != "" && !e.HasModule(mm.ReplaceModule) {
+			errs = append(errs, field.NotFound(field.NewPath("moduleMappings").Index(i).Child("replaceModule"), mm.ReplaceModule))
+		}
+	}
+	for i, mr := range c.ModuleRules {
+		if mr.Module != "" && !e.HasModule(mr.Module) {
+			errs = append(errs, field.NotFound(field.NewPath("moduleRules").Index(i).Child("module"), mr.Module))
+		}
+	}
+	for _, protocol := range sortedKeys(c.ProtocolModuleMappings) {
+		if module := c.ProtocolModuleMappings[protocol]; module != "" && !e.HasModule(module) {
+			errs = append(errs, field.NotFound(field.NewPath("protocolModuleMappings").Key(protocol), module))
+		}
+	}
+	return errs
+}
+
+// validate checks the alerts and parses the expressions of the templates.
+func (a *AlertsConfig) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if a.Severity != "" {
+		for _, msg := range validation.IsValidLabelValue(a.Severity) {
+			errs = append(errs, field.Invalid(path.Child("severity"), a.Severity, msg))
+		}
+	}
+	if a.SeverityLabel != "" {
+		for _, msg := range validation.IsQualifiedName(a.SeverityLabel) {
+			errs = append(errs, field.Invalid(path.Child("severityLabel"), a.SeverityLabel, msg))
+		}
+	}
+	if a.CertExpiryThreshold != "" {
+		_, durationErrs := validateDuration(string(a.CertExpiryThreshold), path.Child("certExpiryThreshold"))
+		errs = append(errs, durationErrs...)
+	}
+	alerts := map[string]bool{}
+	for i := range a.Templates {
+		at := &a.Templates[i]
+		templatePath := path.Child("templates").Index(i)
+		switch {
+		case at.Alert == "":
+			errs = append(errs, field.Required(templatePath.Child("alert"), ""))
+		case alerts[at.Alert]:
+			errs = append(errs, field.Duplicate(templatePath.Child("alert"), at.Alert))
+		case !model.IsValidMetricName(model.LabelValue(at.Alert)):
+			errs = append(errs, field.Invalid(templatePath.Child("alert"), at.Alert, "must be a valid metric name"))
+		}
+		alerts[at.Alert] = true
+		if at.Expr == "" {
+			errs = append(errs, field.Required(templatePath.Child("expr"), ""))
+		} else {
+			tmpl, err := parseExpr(at.Alert, at.Expr)
+			if err == nil {
+				// unknown fields only fail on execution
+				err = tmpl.Execute(io.Discard, AlertExprData{})
+			}
+			if err != nil {
+				errs = append(errs, field.Invalid(templatePath.Child("expr"), at.Expr, err.Error()))
+			} else {
+				at.exprTemplate = tmpl
+			}
+		}
+		if at.For != "" {
+			if _, err := model.ParseDuration(string(at.For)); err != nil {
+				errs = append(errs, field.Invalid(templatePath.Child("for"), at.For, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+func validateDuration(value string, path *field.Path) (time.Duration, field.ErrorList) {
+	d, err := model.ParseDuration(value)
+	if err != nil {
+		return 0, field.ErrorList{field.Invalid(path, value, err.Error())}
+	}
+	if d <= 0 {
+		return 0, field.ErrorList{field.Invalid(path, value, "must be greater than 0")}
+	}
+	return time.Duration(d), nil
+}
+
+func validateNamespaces(namespaces []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, ns := range namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(path.Index(i), ns, msg))
+		}
+	}
+	return errs
+}
+
+func enumNames(values map[string]int32) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validatePort(port uint32, path *field.Path) field.ErrorList {
+	if port > 65535 {
+		return field.ErrorList{field.Invalid(path, port, validation.InclusiveRangeError(1, 65535))}
+	}
+	if port == 0 {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	return nil
+}
+
+func compile(pattern string, path *field.Path) (*regexp.Regexp, field.ErrorList) {
+	if pattern == "" {
+		return nil, field.ErrorList{field.Required(path, "")}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, field.ErrorList{field.Invalid(path, pattern, err.Error())}
+	}
+	return re, nil
+}
@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+// Load reads the first document of a YAML file into obj.
+func Load(filename string, obj interface{}) error {
+	docs, err := LoadAll(filename)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("%s: no document found", filename)
+	}
+	return json.Unmarshal(docs[0], obj)
+}
+
+// LoadAll reads every document of a YAML file and returns them converted to JSON.
+// Empty documents are skipped.
+func LoadAll(filename string) ([][]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := ToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return docs, nil
+}
+
+// ToJSON converts every document of a YAML stream to JSON.
+func ToJSON(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			continue
+		}
+		result, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, result)
+	}
+}
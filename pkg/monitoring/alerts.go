@@ -0,0 +1,125 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MapAlerts returns the PrometheusRule with the alerts of the Source. It returns nil if alerts
+// are disabled or every target is skipped. The alerts match the series of the targets by the
+// labels of the Source and the instance label, which is the target.
+func MapAlerts(cfg *config.Config, src *Source, targets *Targets) (*monitoringv1.PrometheusRule, error) {
+	if !cfg.Alerts.Enabled {
+		return nil, nil
+	}
+	if len(targets.targets) == 0 {
+		return nil, nil
+	}
+	name, err := getName(cfg, src)
+	if err != nil {
+		return nil, err
+	}
+
+	var all, https []string
+	for _, t := range targets.targets {
+		all = append(all, t.target)
+		if strings.HasPrefix(t.target, "https://") {
+			https = append(https, t.target)
+		}
+	}
+	data := config.AlertExprData{
+		Name:                src.Name,
+		Namespace:           src.Namespace,
+		Kind:                src.Kind,
+		Selector:            seriesSelector(src, all),
+		HTTPSSelector:       seriesSelector(src, https),
+		CertExpiryThreshold: int64(cfg.Alerts.CertExpiry().Seconds()),
+	}
+
+	var rules []monitoringv1.Rule
+	for _, at := range cfg.Alerts.AlertTemplates() {
+		if at.HTTPSOnly && len(https) == 0 {
+			continue
+		}
+		tmpl := at.ExprTemplate()
+		if tmpl == nil {
+			return nil, fmt.Errorf("alert %s: invalid expr %q", at.Alert, at.Expr)
+		}
+		var expr strings.Builder
+		if err := tmpl.Execute(&expr, data); err != nil {
+			return nil, fmt.Errorf("alert %s: %w", at.Alert, err)
+		}
+		rule := monitoringv1.Rule{
+			Alert:       at.Alert,
+			Expr:        intstr.FromString(expr.String()),
+			Labels:      map[string]string{},
+			Annotations: at.Annotations,
+		}
+		for k, v := range at.Labels {
+			rule.Labels[k] = v
+		}
+		rule.Labels["severity"] = severity(cfg, src, &targets.overrides, at.Labels["severity"])
+		if at.For != "" {
+			forDuration := at.For
+			rule.For = &forDuration
+		}
+		rules = append(rules, rule)
+	}
+
+	return &monitoringv1.PrometheusRule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: monitoringv1.SchemeGroupVersion.String(),
+			Kind:       monitoringv1.PrometheusRuleKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: src.Namespace,
+			Labels:    getLabels(src, cfg.Alerts.RuleLabels),
+		},
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{{Name: src.Namespace + "/" + name, Rules: rules}},
+		},
+	}, nil
+}
+
+// severity returns the severity of an alert of the Source: the one set by annotation, then the
+// one of the severity label of the Source, then the one of the template and finally the one of
+// the config.
+func severity(cfg *config.Config, src *Source, overrides *Overrides, templateSeverity string) string {
+	if overrides.Severity != "" {
+		return overrides.Severity
+	}
+	if cfg.Alerts.SeverityLabel != "" {
+		if value := src.Labels[cfg.Alerts.SeverityLabel]; value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+			return value
+		}
+	}
+	if templateSeverity != "" {
+		return templateSeverity
+	}
+	return cfg.Alerts.AlertSeverity()
+}
+
+// seriesSelector returns the label matchers, without braces, of the series of the targets of
+// the Source.
+func seriesSelector(src *Source, targets []string) string {
+	sorted := append([]string(nil), targets...)
+	sort.Strings(sorted)
+	quoted := make([]string, 0, len(sorted))
+	for _, target := range sorted {
+		if !slices.Contains(quoted, regexp.QuoteMeta(target)) {
+			quoted = append(quoted, regexp.QuoteMeta(target))
+		}
+	}
+	return fmt.Sprintf("%s,instance=~%s", SourceSelector(src.Kind, src.Namespace, src.Name), strconv.Quote(strings.Join(quoted, "|")))
+}
@@ -0,0 +1,103 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/test/utils"
+)
+
+func TestMapAlerts(t *testing.T) {
+	tests := []*struct {
+		name                   string
+		configFileName         string
+		serviceEntryFilename   string
+		prometheusRuleFilename string
+	}{
+		{
+			name:                   "10 PrometheusRule with severity label",
+			configFileName:         "./testdata/10-config.yaml",
+			serviceEntryFilename:   "./testdata/10-service-entry.yaml",
+			prometheusRuleFilename: "./testdata/10-prometheus-rule.yaml",
+		},
+	}
+	for _, tt := range tests {
+		se, err := utils.LoadServiceEntry(tt.serviceEntryFilename)
+		if err != nil {
+			t.Errorf("%s: loadServiceEntry failed: '%v'", tt.name, err)
+		}
+		rule, err := utils.LoadPrometheusRule(tt.prometheusRuleFilename)
+		if err != nil {
+			t.Errorf("%s: loadPrometheusRule failed: '%v'", tt.name, err)
+		}
+		cfg, err := config.LoadConfig(tt.configFileName)
+		if err != nil {
+			t.Errorf("%s: loadConfig failed: '%v'", tt.name, err)
+		}
+		logger := logr.Discard()
+		src := FromServiceEntry(se)
+		generated, err := MapAlerts(cfg, src, GenerateTargets(cfg, &logger, src, nil))
+		if err != nil {
+			t.Errorf("%s: MapAlerts failed: '%v'", tt.name, err)
+		}
+		if diff := cmp.Diff(rule, generated); diff != "" {
+			t.Errorf("%s: PrometheusRule mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
+
+func TestAlertSeverity(t *testing.T) {
+	cfg := &config.Config{
+		DefaultModule:               "http_2xx",
+		ServiceMonitorNamingPattern: "sm-%s",
+		Alerts: config.AlertsConfig{
+			Enabled:       true,
+			SeverityLabel: "tier",
+			Templates: []config.AlertTemplate{
+				{Alert: "ProbeFailed", Expr: "probe_success{ {{ .Selector }} } == 0"},
+				{Alert: "ProbeSlow", Expr: "probe_duration_seconds{ {{ .Selector }} } > 5", Labels: map[string]string{"severity": "info"}},
+				{Alert: "CertificateExpiry", Expr: "probe_ssl_earliest_cert_expiry{ {{ .HTTPSSelector }} } < 0", HTTPSOnly: true},
+			},
+		},
+	}
+	logger := logr.Discard()
+	src := &Source{Kind: KindServiceEntry, Hosts: []string{"api.example.com"}, Ports: []Port{{Name: "http", Number: 80, Protocol: "HTTP"}}}
+	src.Name, src.Namespace = "api", "team-a"
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        map[string]string
+	}{
+		{name: "config", want: map[string]string{"ProbeFailed": "warning", "ProbeSlow": "info"}},
+		{name: "label", labels: map[string]string{"tier": "critical"}, want: map[string]string{"ProbeFailed": "critical", "ProbeSlow": "critical"}},
+		{
+			name:        "annotation",
+			labels:      map[string]string{"tier": "critical"},
+			annotations: map[string]string{SeverityAnnotation: "page"},
+			want:        map[string]string{"ProbeFailed": "page", "ProbeSlow": "page"},
+		},
+	}
+	for _, tt := range tests {
+		src.Labels, src.Annotations = tt.labels, tt.annotations
+		rule, err := MapAlerts(cfg, src, GenerateTargets(cfg, &logger, src, nil))
+		if err != nil {
+			t.Fatalf("%s: MapAlerts failed: '%v'", tt.name, err)
+		}
+		got := map[string]string{}
+		for _, r := range rule.Spec.Groups[0].Rules {
+			got[r.Alert] = r.Labels["severity"]
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: severity mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+
+	cfg.Alerts.Enabled = false
+	if rule, err := MapAlerts(cfg, src, GenerateTargets(cfg, &logger, src, nil)); rule != nil || err != nil {
+		t.Errorf("expected no PrometheusRule with disabled alerts, got %v, %v", rule, err)
+	}
+}
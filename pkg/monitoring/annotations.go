@@ -0,0 +1,234 @@
+package monitoring
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// AnnotationPrefix is the prefix of all annotations read from a ServiceEntry or Service.
+	AnnotationPrefix = "blackbox.schmiddim.io/"
+	// ModuleAnnotation sets the module of all targets.
+	ModuleAnnotation = AnnotationPrefix + "module"
+	// IntervalAnnotation sets the scrape interval.
+	IntervalAnnotation = AnnotationPrefix + "interval"
+	// ScrapeTimeoutAnnotation sets the scrape timeout, it must not be greater than the interval.
+	ScrapeTimeoutAnnotation = AnnotationPrefix + "scrape-timeout"
+	// PathAnnotation sets the path of the probed URL, replacing a path from the host mappings.
+	PathAnnotation = AnnotationPrefix + "path"
+	// SkipPortsAnnotation is a comma separated list of port numbers and names that are not probed.
+	SkipPortsAnnotation = AnnotationPrefix + "skip-ports"
+	// SkipHostsAnnotation is a comma separated list of host globs, e.g. *.internal.example.com,
+	// that are not probed.
+	SkipHostsAnnotation = AnnotationPrefix + "skip-hosts"
+	// SeverityAnnotation sets the severity of the generated alerts.
+	SeverityAnnotation = AnnotationPrefix + "severity"
+
+	// portModulePrefix and portModuleSuffix enclose the port number of
+	// blackbox.schmiddim.io/port.<n>.module, which sets the module of the targets of one port.
+	portModulePrefix = AnnotationPrefix + "port."
+	portModuleSuffix = ".module"
+)
+
+// Overrides are the settings of a Source after applying its annotations to the config.
+type Overrides struct {
+	// Module of all targets, empty to use the module mappings of the config
+	Module string
+	// PortModules by port number, they take precedence over Module
+	PortModules   map[uint32]string
+	Interval      monitoringv1.Duration
+	ScrapeTimeout monitoringv1.Duration
+	// Path of the probed URL, empty to keep the one of the host mappings
+	Path string
+	// SkipPorts are port numbers and names that are not probed
+	SkipPorts []string
+	// SkipHosts are globs of hosts that are not probed
+	SkipHosts []string
+	// Severity of the generated alerts, empty to use the one of the config
+	Severity string
+}
+
+// module returns the module set by annotation for the port, empty if there is none.
+func (o *Overrides) module(port uint32) string {
+	if module, ok := o.PortModules[port]; ok {
+		return module
+	}
+	return o.Module
+}
+
+// skipsPort reports whether the port is skipped by number or by name.
+func (o *Overrides) skipsPort(port *Port) bool {
+	number := strconv.FormatUint(uint64(port.Number), 10)
+	for _, skipped := range o.SkipPorts {
+		if skipped == number || skipped == port.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// skipsHost reports whether the host matches one of the skipped globs.
+func (o *Overrides) skipsHost(host string) bool {
+	for _, pattern := range o.SkipHosts {
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseOverrides applies the annotations of a Source to the config. Invalid annotations
+// are ignored and returned as errors. A scrape timeout of the config greater than an annotated
+// interval is capped at the interval.
+func ParseOverrides(cfg *config.Config, annotations map[string]string) (Overrides, field.ErrorList) {
+	o := Overrides{
+		PortModules:   map[uint32]string{},
+		Interval:      cfg.Interval,
+		ScrapeTimeout: cfg.ScrapeTimeout,
+	}
+	var errs field.ErrorList
+	annotationsPath := field.NewPath("metadata", "annotations")
+
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		if strings.HasPrefix(key, AnnotationPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := annotations[key]
+		switch {
+		case key == ModuleAnnotation:
+			if moduleErrs := validateModule(cfg, value, annotationsPath.Key(key)); len(moduleErrs) > 0 {
+				errs = append(errs, moduleErrs...)
+				continue
+			}
+			o.Module = value
+		case key == IntervalAnnotation:
+			if _, durationErrs := parseDuration(value, annotationsPath.Key(key)); len(durationErrs) > 0 {
+				errs = append(errs, durationErrs...)
+				continue
+			}
+			o.Interval = monitoringv1.Duration(value)
+		case key == SkipPortsAnnotation:
+			ports, listErrs := parseList(value, annotationsPath.Key(key))
+			errs = append(errs, listErrs...)
+			for _, port := range ports {
+				if number, err := strconv.ParseUint(port, 10, 32); err == nil && (number < 1 || number > 65535) {
+					errs = append(errs, field.Invalid(annotationsPath.Key(key), port, "port "+validation.InclusiveRangeError(1, 65535)))
+					continue
+				}
+				o.SkipPorts = append(o.SkipPorts, port)
+			}
+		case key == SkipHostsAnnotation:
+			hosts, listErrs := parseList(value, annotationsPath.Key(key))
+			errs = append(errs, listErrs...)
+			for _, host := range hosts {
+				if _, err := path.Match(host, ""); err != nil {
+					errs = append(errs, field.Invalid(annotationsPath.Key(key), host, err.Error()))
+					continue
+				}
+				o.SkipHosts = append(o.SkipHosts, host)
+			}
+		case key == SeverityAnnotation:
+			if msgs := validation.IsValidLabelValue(value); value == "" || len(msgs) > 0 {
+				errs = append(errs, field.Invalid(annotationsPath.Key(key), value, "must be a non-empty label value"))
+				continue
+			}
+			o.Severity = value
+		case key == ScrapeTimeoutAnnotation, key == PathAnnotation:
+			// applied after the interval is known
+		case strings.HasPrefix(key, portModulePrefix) && strings.HasSuffix(key, portModuleSuffix):
+			number := strings.TrimSuffix(strings.TrimPrefix(key, portModulePrefix), portModuleSuffix)
+			port, err := strconv.ParseUint(number, 10, 32)
+			if err != nil || port < 1 || port > 65535 {
+				errs = append(errs, field.Invalid(annotationsPath.Key(key), number, "port "+validation.InclusiveRangeError(1, 65535)))
+				continue
+			}
+			if moduleErrs := validateModule(cfg, value, annotationsPath.Key(key)); len(moduleErrs) > 0 {
+				errs = append(errs, moduleErrs...)
+				continue
+			}
+			o.PortModules[uint32(port)] = value
+		default:
+			errs = append(errs, field.Invalid(annotationsPath.Key(key), value, "unknown annotation"))
+		}
+	}
+
+	interval, intervalErrs := parseDuration(string(o.Interval), nil)
+	if value, ok := annotations[ScrapeTimeoutAnnotation]; ok {
+		timeout, durationErrs := parseDuration(value, annotationsPath.Key(ScrapeTimeoutAnnotation))
+		switch {
+		case len(durationErrs) > 0:
+			errs = append(errs, durationErrs...)
+		case len(intervalErrs) == 0 && timeout > interval:
+			errs = append(errs, field.Invalid(annotationsPath.Key(ScrapeTimeoutAnnotation), value,
+				"must not be greater than interval "+string(o.Interval)))
+		default:
+			o.ScrapeTimeout = monitoringv1.Duration(value)
+		}
+	}
+	if timeout, durationErrs := parseDuration(string(o.ScrapeTimeout), nil); len(durationErrs) == 0 && len(intervalErrs) == 0 && timeout > interval {
+		o.ScrapeTimeout = o.Interval
+	}
+
+	if value, ok := annotations[PathAnnotation]; ok {
+		if !strings.HasPrefix(value, "/") || strings.ContainsAny(value, " ?#") {
+			errs = append(errs, field.Invalid(annotationsPath.Key(PathAnnotation), value, "must be an absolute path without query or fragment"))
+		} else {
+			o.Path = value
+		}
+	}
+	return o, errs
+}
+
+// parseList splits a comma separated list, empty items are errors.
+func parseList(value string, fldPath *field.Path) ([]string, field.ErrorList) {
+	var items []string
+	var errs field.ErrorList
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			errs = append(errs, field.Invalid(fldPath, value, "must be a comma separated list without empty items"))
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, errs
+}
+
+// validateModule checks the module of an annotation, it must be declared if the config of the
+// exporter is managed.
+func validateModule(cfg *config.Config, module string, fldPath *field.Path) field.ErrorList {
+	if module == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	if strings.ContainsAny(module, " \t\n") {
+		return field.ErrorList{field.Invalid(fldPath, module, "must not contain whitespace")}
+	}
+	if !cfg.Exporter.HasModule(module) {
+		return field.ErrorList{field.NotFound(fldPath, module)}
+	}
+	return nil
+}
+
+func parseDuration(value string, fldPath *field.Path) (time.Duration, field.ErrorList) {
+	d, err := model.ParseDuration(value)
+	if err != nil {
+		return 0, field.ErrorList{field.Invalid(fldPath, value, err.Error())}
+	}
+	if d <= 0 {
+		return 0, field.ErrorList{field.Invalid(fldPath, value, "must be greater than 0")}
+	}
+	return time.Duration(d), nil
+}
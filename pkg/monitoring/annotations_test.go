@@ -0,0 +1,142 @@
+package monitoring
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+)
+
+func TestParseOverrides(t *testing.T) {
+	cfg := &config.Config{Interval: "30s", ScrapeTimeout: "10s"}
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		module        string
+		portModules   map[uint32]string
+		interval      monitoringv1.Duration
+		scrapeTimeout monitoringv1.Duration
+		path          string
+		skipPorts     []string
+		skipHosts     []string
+		severity      string
+		errs          []string
+	}{
+		{name: "no annotations", interval: "30s", scrapeTimeout: "10s"},
+		{
+			name:        "foreign annotations are ignored",
+			annotations: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+			interval:    "30s", scrapeTimeout: "10s",
+		},
+		{
+			name: "all annotations",
+			annotations: map[string]string{
+				ModuleAnnotation:                         "tcp_connect",
+				"blackbox.schmiddim.io/port.8443.module": "http_2xx",
+				IntervalAnnotation:                       "1m",
+				ScrapeTimeoutAnnotation:                  "30s",
+				PathAnnotation:                           "/healthz",
+				SeverityAnnotation:                       "critical",
+			},
+			module:      "tcp_connect",
+			severity:    "critical",
+			portModules: map[uint32]string{8443: "http_2xx"},
+			interval:    "1m", scrapeTimeout: "30s", path: "/healthz",
+		},
+		{
+			name: "skip lists",
+			annotations: map[string]string{
+				SkipPortsAnnotation: "443, 8443,https-alt",
+				SkipHostsAnnotation: "*.internal.example.com,api.example.com",
+			},
+			interval: "30s", scrapeTimeout: "10s",
+			skipPorts: []string{"443", "8443", "https-alt"},
+			skipHosts: []string{"*.internal.example.com", "api.example.com"},
+		},
+		{
+			name: "invalid skip lists",
+			annotations: map[string]string{
+				SkipPortsAnnotation: "443,,70000",
+				SkipHostsAnnotation: "[a-",
+			},
+			interval: "30s", scrapeTimeout: "10s",
+			skipPorts: []string{"443"},
+			errs: []string{
+				`metadata.annotations[blackbox.schmiddim.io/skip-hosts]: Invalid value: "[a-": syntax error in pattern`,
+				`metadata.annotations[blackbox.schmiddim.io/skip-ports]: Invalid value: "443,,70000": must be a comma separated list`,
+				`metadata.annotations[blackbox.schmiddim.io/skip-ports]: Invalid value: "70000"`,
+			},
+		},
+		{
+			name:        "config timeout is capped at a shorter interval",
+			annotations: map[string]string{IntervalAnnotation: "5s"},
+			interval:    "5s", scrapeTimeout: "5s",
+		},
+		{
+			name: "invalid values fall back to the config",
+			annotations: map[string]string{
+				IntervalAnnotation:                       "often",
+				ScrapeTimeoutAnnotation:                  "1m",
+				PathAnnotation:                           "healthz",
+				ModuleAnnotation:                         "",
+				"blackbox.schmiddim.io/port.0.module":    "http_2xx",
+				"blackbox.schmiddim.io/scrape-intervall": "1m",
+				SeverityAnnotation:                       "very high",
+			},
+			interval: "30s", scrapeTimeout: "10s",
+			errs: []string{
+				`metadata.annotations[blackbox.schmiddim.io/interval]: Invalid value: "often"`,
+				`metadata.annotations[blackbox.schmiddim.io/module]: Required value`,
+				`metadata.annotations[blackbox.schmiddim.io/port.0.module]: Invalid value: "0"`,
+				`metadata.annotations[blackbox.schmiddim.io/scrape-intervall]: Invalid value: "1m": unknown annotation`,
+				`metadata.annotations[blackbox.schmiddim.io/severity]: Invalid value: "very high"`,
+				`metadata.annotations[blackbox.schmiddim.io/scrape-timeout]: Invalid value: "1m": must not be greater than interval 30s`,
+				`metadata.annotations[blackbox.schmiddim.io/path]: Invalid value: "healthz"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, errs := ParseOverrides(cfg, tt.annotations)
+		if len(errs) != len(tt.errs) {
+			t.Errorf("%s: expected %d errors, got %v", tt.name, len(tt.errs), errs)
+		} else {
+			for i, err := range errs {
+				if !strings.HasPrefix(err.Error(), tt.errs[i]) {
+					t.Errorf("%s: expected error %q, got %q", tt.name, tt.errs[i], err.Error())
+				}
+			}
+		}
+		if got.Module != tt.module || got.Interval != tt.interval || got.ScrapeTimeout != tt.scrapeTimeout || got.Path != tt.path || got.Severity != tt.severity {
+			t.Errorf("%s: unexpected overrides %+v", tt.name, got)
+		}
+		if !reflect.DeepEqual(got.SkipPorts, tt.skipPorts) || !reflect.DeepEqual(got.SkipHosts, tt.skipHosts) {
+			t.Errorf("%s: expected skipped ports %v and hosts %v, got %v and %v", tt.name, tt.skipPorts, tt.skipHosts, got.SkipPorts, got.SkipHosts)
+		}
+		if len(got.PortModules) != len(tt.portModules) {
+			t.Errorf("%s: expected port modules %v, got %v", tt.name, tt.portModules, got.PortModules)
+		}
+		for port, module := range tt.portModules {
+			if got.PortModules[port] != module {
+				t.Errorf("%s: expected module %s for port %d, got %s", tt.name, module, port, got.PortModules[port])
+			}
+		}
+	}
+}
+
+func TestParseOverrides_DeclaredModules(t *testing.T) {
+	cfg := &config.Config{Interval: "30s", ScrapeTimeout: "10s", Exporter: config.ExporterConfig{
+		Modules: map[string]config.Module{"http_2xx": {Prober: "http"}, "tcp_connect": {Prober: "tcp"}},
+	}}
+	got, errs := ParseOverrides(cfg, map[string]string{
+		ModuleAnnotation:                         "tcp_connect",
+		"blackbox.schmiddim.io/port.8443.module": "http_tls",
+	})
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), `metadata.annotations[blackbox.schmiddim.io/port.8443.module]: Not found: "http_tls"`) {
+		t.Errorf("expected an error for the undeclared module, got %v", errs)
+	}
+	if got.Module != "tcp_connect" || len(got.PortModules) != 0 {
+		t.Errorf("unexpected overrides %+v", got)
+	}
+}
@@ -1,22 +1,44 @@
 package monitoring
 
-import "github.com/schmiddim/blackbox-operator/pkg/config"
+import (
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
 
+// Excluded decides by their labels which ServiceEntries are not probed: ServiceEntries that do
+// not match Config.IncludeSelector or that match Config.ExcludeSelector. An empty include selector
+// includes every ServiceEntry, an empty exclude selector excludes none. An invalid selector
+// excludes every ServiceEntry, ParseConfig rejects those.
 type Excluded struct {
-	cfg *config.Config
+	include labels.Selector
+	exclude labels.Selector
 }
 
 func NewExcluded(cfg *config.Config) *Excluded {
-	return &Excluded{cfg: cfg}
-}
-
-func (e *Excluded) IsExcluded(labels map[string]string) bool {
-	for k, v := range labels {
-		for ek, ev := range e.cfg.ExcludeSelector.MatchLabels {
-			if k == ek && v == ev {
-				return true
-			}
+	e := &Excluded{include: labels.Everything(), exclude: labels.Nothing()}
+	if !isEmpty(&cfg.IncludeSelector) {
+		selector, err := metav1.LabelSelectorAsSelector(&cfg.IncludeSelector)
+		if err != nil {
+			selector = labels.Nothing()
+		}
+		e.include = selector
+	}
+	if !isEmpty(&cfg.ExcludeSelector) {
+		selector, err := metav1.LabelSelectorAsSelector(&cfg.ExcludeSelector)
+		if err != nil {
+			selector = labels.Everything()
 		}
+		e.exclude = selector
 	}
-	return false
+	return e
+}
+
+func (e *Excluded) IsExcluded(seLabels map[string]string) bool {
+	set := labels.Set(seLabels)
+	return !e.include.Matches(set) || e.exclude.Matches(set)
+}
+
+func isEmpty(selector *metav1.LabelSelector) bool {
+	return len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0
 }
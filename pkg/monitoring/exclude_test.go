@@ -0,0 +1,64 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsExcluded(t *testing.T) {
+	optIn := metav1.LabelSelector{MatchLabels: map[string]string{"blackbox.io/probe": "true"}}
+	tests := []struct {
+		name    string
+		include metav1.LabelSelector
+		exclude metav1.LabelSelector
+		labels  map[string]string
+		want    bool
+	}{
+		{name: "no selectors", labels: map[string]string{"team": "a"}, want: false},
+		{
+			name:    "matchLabels",
+			exclude: metav1.LabelSelector{MatchLabels: map[string]string{"blackbox-operator-scrape": "false"}},
+			labels:  map[string]string{"blackbox-operator-scrape": "false"},
+			want:    true,
+		},
+		{
+			name:    "matchLabels are ANDed",
+			exclude: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a", "env": "dev"}},
+			labels:  map[string]string{"team": "a", "env": "prod"},
+			want:    false,
+		},
+		{
+			name: "matchExpressions",
+			exclude: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev", "test"}},
+			}},
+			labels: map[string]string{"env": "test"},
+			want:   true,
+		},
+		{name: "opt-in without label", include: optIn, labels: map[string]string{"team": "a"}, want: true},
+		{name: "opt-in with label", include: optIn, labels: map[string]string{"blackbox.io/probe": "true"}, want: false},
+		{
+			name:    "opt-in with label but excluded",
+			include: optIn,
+			exclude: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			labels:  map[string]string{"blackbox.io/probe": "true", "team": "a"},
+			want:    true,
+		},
+		{
+			name: "invalid exclude excludes everything",
+			exclude: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "env", Operator: "Unknown"},
+			}},
+			labels: map[string]string{"env": "prod"},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{IncludeSelector: tt.include, ExcludeSelector: tt.exclude}
+		if got := NewExcluded(cfg).IsExcluded(tt.labels); got != tt.want {
+			t.Errorf("%s: expected excluded %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package monitoring
+
+import (
+	"slices"
+
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// KindHTTPRoute is the kind of Sources created from a Gateway API HTTPRoute.
+const KindHTTPRoute = "HTTPRoute"
+
+// FromHTTPRoute returns the Source of an HTTPRoute. Its hostnames are probed on the HTTP and HTTPS
+// listeners of the parent Gateways they are attached to, a route without hostnames gets the
+// hostname of the listener. Every hostname is probed once per Exact or PathPrefix path of its
+// rules. Wildcard hostnames and parents that are not in gateways are not probed.
+func FromHTTPRoute(route *gatewayv1.HTTPRoute, gateways []*gatewayv1.Gateway) *Source {
+	src := &Source{
+		ObjectMeta: route.ObjectMeta,
+		Kind:       KindHTTPRoute,
+		Paths:      map[string][]string{},
+	}
+
+	var paths []string
+	for _, rule := range route.Spec.Rules {
+		if len(rule.Matches) == 0 {
+			paths = appendPath(paths, "/")
+		}
+		for _, match := range rule.Matches {
+			if match.Path == nil {
+				paths = appendPath(paths, "/")
+				continue
+			}
+			if match.Path.Type != nil && *match.Path.Type != gatewayv1.PathMatchExact && *match.Path.Type != gatewayv1.PathMatchPathPrefix {
+				continue
+			}
+			if match.Path.Value != nil {
+				paths = appendPath(paths, *match.Path.Value)
+			}
+		}
+	}
+
+	for _, ref := range route.Spec.ParentRefs {
+		gateway := parentGateway(ref, route.Namespace, gateways)
+		if gateway == nil {
+			continue
+		}
+		for _, listener := range gateway.Spec.Listeners {
+			if !attachesTo(ref, &listener) {
+				continue
+			}
+			for _, host := range listenerHosts(route.Spec.Hostnames, listener.Hostname) {
+				src.addHost(host, &listener)
+			}
+		}
+	}
+	for _, host := range src.Hosts {
+		src.Paths[host] = paths
+	}
+	dropRootPaths(src.Paths)
+	return src
+}
+
+// parentGateway returns the Gateway the parent reference points to, nil if it is not a Gateway
+// or not in gateways.
+func parentGateway(ref gatewayv1.ParentReference, namespace string, gateways []*gatewayv1.Gateway) *gatewayv1.Gateway {
+	if (ref.Group != nil && *ref.Group != gatewayv1.GroupName) || (ref.Kind != nil && *ref.Kind != "Gateway") {
+		return nil
+	}
+	if ref.Namespace != nil {
+		namespace = string(*ref.Namespace)
+	}
+	for _, gateway := range gateways {
+		if gateway.Namespace == namespace && gateway.Name == string(ref.Name) {
+			return gateway
+		}
+	}
+	return nil
+}
+
+// attachesTo reports whether a route with the parent reference attaches to the listener.
+func attachesTo(ref gatewayv1.ParentReference, listener *gatewayv1.Listener) bool {
+	if listener.Protocol != gatewayv1.HTTPProtocolType && listener.Protocol != gatewayv1.HTTPSProtocolType {
+		return false
+	}
+	if ref.SectionName != nil && *ref.SectionName != listener.Name {
+		return false
+	}
+	return ref.Port == nil || *ref.Port == listener.Port
+}
+
+// listenerHosts returns the probeable hostnames of a route on a listener: the intersection of
+// the route hostnames with the hostname of the listener.
+func listenerHosts(routeHostnames []gatewayv1.Hostname, listenerHostname *gatewayv1.Hostname) []string {
+	var hosts []string
+	if len(routeHostnames) == 0 {
+		if listenerHostname != nil && isProbeableHost(string(*listenerHostname)) {
+			hosts = append(hosts, string(*listenerHostname))
+		}
+		return hosts
+	}
+	for _, hostname := range routeHostnames {
+		host := string(hostname)
+		switch {
+		case listenerHostname == nil || hostnameMatches(string(*listenerHostname), host):
+		case hostnameMatches(host, string(*listenerHostname)):
+			// a wildcard route hostname covers the listener hostname
+			host = string(*listenerHostname)
+		default:
+			continue
+		}
+		if isProbeableHost(host) && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// addHost adds the host on the port of the listener, ports are shared by listeners with the
+// same number and protocol.
+func (s *Source) addHost(host string, listener *gatewayv1.Listener) {
+	if !slices.Contains(s.Hosts, host) {
+		s.Hosts = append(s.Hosts, host)
+	}
+	for i := range s.Ports {
+		port := &s.Ports[i]
+		if port.Number == uint32(listener.Port) && port.Protocol == string(listener.Protocol) {
+			if !slices.Contains(port.Hosts, host) {
+				port.Hosts = append(port.Hosts, host)
+			}
+			return
+		}
+	}
+	s.Ports = append(s.Ports, Port{
+		Name:     string(listener.Name),
+		Number:   uint32(listener.Port),
+		Protocol: string(listener.Protocol),
+		Hosts:    []string{host},
+	})
+}
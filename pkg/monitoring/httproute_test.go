@@ -0,0 +1,73 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestHTTPRoute(t *testing.T) {
+	gateway := &gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "public", Namespace: "gateways"},
+		Spec: gatewayv1.GatewaySpec{Listeners: []gatewayv1.Listener{
+			{Name: "https", Port: 443, Protocol: gatewayv1.HTTPSProtocolType, Hostname: ptr.To[gatewayv1.Hostname]("*.example.com")},
+			{Name: "http", Port: 80, Protocol: gatewayv1.HTTPProtocolType},
+			{Name: "tcp", Port: 5432, Protocol: gatewayv1.TCPProtocolType},
+		}},
+	}
+	parent := gatewayv1.ParentReference{Name: "public", Namespace: ptr.To[gatewayv1.Namespace]("gateways")}
+	exact := gatewayv1.PathMatchExact
+	regex := gatewayv1.PathMatchRegularExpression
+
+	tests := []struct {
+		name string
+		spec gatewayv1.HTTPRouteSpec
+		want []string
+	}{
+		{
+			name: "all listeners",
+			spec: gatewayv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{parent}},
+				Hostnames:       []gatewayv1.Hostname{"www.example.com", "www.example.org"},
+			},
+			want: []string{"https://www.example.com:443", "www.example.com:80", "www.example.org:80"},
+		},
+		{
+			name: "section and paths",
+			spec: gatewayv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{
+					{Name: "public", Namespace: ptr.To[gatewayv1.Namespace]("gateways"), SectionName: ptr.To[gatewayv1.SectionName]("https")},
+				}},
+				Hostnames: []gatewayv1.Hostname{"api.example.com"},
+				Rules: []gatewayv1.HTTPRouteRule{{Matches: []gatewayv1.HTTPRouteMatch{
+					{Path: &gatewayv1.HTTPPathMatch{Type: &exact, Value: ptr.To("/health")}},
+					{Path: &gatewayv1.HTTPPathMatch{Type: &regex, Value: ptr.To("/v[0-9]+")}},
+					{Path: &gatewayv1.HTTPPathMatch{Value: ptr.To("/v1")}},
+				}}},
+			},
+			want: []string{"https://api.example.com:443/health", "https://api.example.com:443/v1"},
+		},
+		{
+			name: "hostname of the listener",
+			spec: gatewayv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{parent}},
+			},
+		},
+		{
+			name: "unknown parent",
+			spec: gatewayv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{{Name: "public"}}},
+				Hostnames:       []gatewayv1.Hostname{"www.example.com"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		route := &gatewayv1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"}, Spec: tt.spec}
+		if diff := cmp.Diff(tt.want, targetsOf(t, FromHTTPRoute(route, []*gatewayv1.Gateway{gateway}))); diff != "" {
+			t.Errorf("%s: unexpected targets (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
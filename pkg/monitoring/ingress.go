@@ -0,0 +1,102 @@
+package monitoring
+
+import (
+	"slices"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// KindIngress is the kind of Sources created from an Ingress.
+const KindIngress = "Ingress"
+
+// FromIngress returns the Source of an Ingress. Every host of its rules is probed with https on
+// port 443 if a TLS section covers it, a section without hosts covers all of them, with http on
+// port 80 otherwise, once per path of its rules.
+// Rules without a host and wildcard hosts are not probed, paths that look like regular
+// expressions are ignored.
+func FromIngress(ing *networkingv1.Ingress) *Source {
+	src := &Source{
+		ObjectMeta: ing.ObjectMeta,
+		Kind:       KindIngress,
+		Paths:      map[string][]string{},
+	}
+	var tlsHosts []string
+	// a TLS section without hosts covers every host of the rules
+	allTLS := false
+	for _, tls := range ing.Spec.TLS {
+		allTLS = allTLS || len(tls.Hosts) == 0
+		tlsHosts = append(tlsHosts, tls.Hosts...)
+	}
+
+	https := Port{Name: "https", Number: 443, Protocol: "HTTPS"}
+	http := Port{Name: "http", Number: 80, Protocol: "HTTP"}
+	for _, rule := range ing.Spec.Rules {
+		if !isProbeableHost(rule.Host) {
+			continue
+		}
+		if !slices.Contains(src.Hosts, rule.Host) {
+			src.Hosts = append(src.Hosts, rule.Host)
+			if allTLS || hostnameMatchesAny(tlsHosts, rule.Host) {
+				https.Hosts = append(https.Hosts, rule.Host)
+			} else {
+				http.Hosts = append(http.Hosts, rule.Host)
+			}
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			src.Paths[rule.Host] = appendPath(src.Paths[rule.Host], path.Path)
+		}
+	}
+	for _, port := range []Port{https, http} {
+		if len(port.Hosts) > 0 {
+			src.Ports = append(src.Ports, port)
+		}
+	}
+	dropRootPaths(src.Paths)
+	return src
+}
+
+// isProbeableHost reports whether the host names a single endpoint.
+func isProbeableHost(host string) bool {
+	return host != "" && !strings.Contains(host, "*")
+}
+
+// hostnameMatches reports whether host matches pattern, which may start with a wildcard label.
+func hostnameMatches(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return pattern == host
+}
+
+func hostnameMatchesAny(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if hostnameMatches(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendPath adds a literal path that is not yet in paths.
+func appendPath(paths []string, path string) []string {
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, `*?()[]{}^$|\`) || slices.Contains(paths, path) {
+		return paths
+	}
+	return append(paths, path)
+}
+
+// dropRootPaths removes the paths of hosts that only serve the root, those are probed without a path.
+func dropRootPaths(paths map[string][]string) {
+	for host, p := range paths {
+		if len(p) == 0 || (len(p) == 1 && p[0] == "/") {
+			delete(paths, host)
+		}
+	}
+}
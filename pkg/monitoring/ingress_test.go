@@ -0,0 +1,88 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// targetsOf returns the targets of the ServiceMonitor generated for the Source.
+func targetsOf(t *testing.T, src *Source) []string {
+	t.Helper()
+	cfg := getCfg()
+	logger := logr.Discard()
+	sm, err := NewServiceMonitorMapper(&cfg, &logger).MapperForService(src, nil)
+	if err != nil {
+		t.Fatalf("MapperForService failed: '%v'", err)
+	}
+	var targets []string
+	for _, e := range sm.Spec.Endpoints {
+		targets = append(targets, e.Params["target"][0])
+	}
+	return targets
+}
+
+func TestIngress(t *testing.T) {
+	prefix := networkingv1.PathTypePrefix
+	paths := func(values ...string) *networkingv1.HTTPIngressRuleValue {
+		rule := &networkingv1.HTTPIngressRuleValue{}
+		for _, v := range values {
+			rule.Paths = append(rule.Paths, networkingv1.HTTPIngressPath{Path: v, PathType: &prefix})
+		}
+		return rule
+	}
+	tests := []struct {
+		name string
+		spec networkingv1.IngressSpec
+		want []string
+	}{
+		{
+			name: "tls and plain hosts",
+			spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}},
+				Rules: []networkingv1.IngressRule{
+					{Host: "www.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths("/")}},
+					{Host: "legacy.example.org", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths("/")}},
+				},
+			},
+			want: []string{"https://www.example.com:443", "legacy.example.org:80"},
+		},
+		{
+			name: "tls without hosts",
+			spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{SecretName: "default-cert"}},
+				Rules: []networkingv1.IngressRule{
+					{Host: "www.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths("/")}},
+					{Host: "legacy.example.org", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths("/")}},
+				},
+			},
+			want: []string{"https://www.example.com:443", "https://legacy.example.org:443"},
+		},
+		{
+			name: "paths",
+			spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com"}}},
+				Rules: []networkingv1.IngressRule{
+					{Host: "shop.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths("/", "/api", "/(.*)")}},
+					{Host: "shop.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: paths("/api", "/cart")}},
+				},
+			},
+			want: []string{"https://shop.example.com:443/", "https://shop.example.com:443/api", "https://shop.example.com:443/cart"},
+		},
+		{
+			name: "no host and wildcard host",
+			spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{Host: ""}, {Host: "*.example.com"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"}, Spec: tt.spec}
+		if diff := cmp.Diff(tt.want, targetsOf(t, FromIngress(ing))); diff != "" {
+			t.Errorf("%s: unexpected targets (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package monitoring
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/pkg/naming"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ManagedByLabel marks every object generated by the operator.
+	ManagedByLabel = "managed-by"
+	// ManagedByValue is the value of ManagedByLabel on generated objects.
+	ManagedByValue = "blackbox-operator"
+	// ForLabel holds the name of the Source an object was generated for.
+	ForLabel = "for"
+)
+
+// Mapper generates the monitoring object for a Source.
+type Mapper interface {
+	// Map returns nil if every target of the Source is skipped. The targets are generated
+	// by GenerateTargets with the config of the Mapper.
+	Map(src *Source, targets *Targets) (client.Object, error)
+}
+
+// NewMapper returns the Mapper for the configured output.
+func NewMapper(cfg *config.Config, log *logr.Logger) Mapper {
+	switch cfg.Output {
+	case config.OutputProbe:
+		return NewProbeMapper(cfg, log)
+	case config.OutputScrapeConfig:
+		return NewScrapeConfigMapper(cfg, log)
+	default:
+		return NewServiceMonitorMapper(cfg, log)
+	}
+}
+
+// probeTarget is a single host and port combination of a Source that gets probed.
+type probeTarget struct {
+	// host as declared in the Source
+	host string
+	// target passed to the blackbox exporter
+	target string
+	module string
+	// rule is the name of the module rule that selected the module, empty if none did
+	rule string
+	// hostMapping and moduleMapping are the patterns of the applied mappings, empty if none was
+	hostMapping   string
+	moduleMapping string
+}
+
+// Targets are the probe targets of a Source. They are generated once per reconcile and shared
+// by the Mapper, Summarize and MapAlerts.
+type Targets struct {
+	overrides Overrides
+	// invalidAnnotations are the errors of the annotations that are ignored
+	invalidAnnotations []string
+	targets            []probeTarget
+	// labels are set on the generated object by the module mappings
+	labels map[string]string
+}
+
+// GenerateTargets returns the Targets of the Source. Ports in tlsPorts are probed with https
+// on their upstream port.
+func GenerateTargets(cfg *config.Config, log *logr.Logger, src *Source, tlsPorts TLSPorts) *Targets {
+	overrides, errs := ParseOverrides(cfg, src.Annotations)
+	t := &Targets{overrides: overrides}
+	for _, err := range errs {
+		t.invalidAnnotations = append(t.invalidAnnotations, err.Error())
+	}
+	t.targets, t.labels = generateTargets(cfg, log, src, &t.overrides, tlsPorts)
+	return t
+}
+
+// getName renders Config.ServiceMonitorNamingPattern for the Source.
+func getName(cfg *config.Config, src *Source) (string, error) {
+	pattern, err := naming.Parse(cfg.ServiceMonitorNamingPattern)
+	if err != nil {
+		return "", err
+	}
+	data := naming.Data{
+		Name:      src.Name,
+		Namespace: src.Namespace,
+		Labels:    src.Labels,
+	}
+	if len(src.Hosts) > 0 {
+		data.Host = src.Hosts[0]
+	}
+	if src.Kind != KindServiceEntry {
+		data.Kind = strings.ToLower(src.Kind)
+	}
+	return pattern.Execute(data)
+}
+
+// getLabels returns the labels of a generated object.
+func getLabels(src *Source, additionalLabels map[string]string) map[string]string {
+	labels := map[string]string{
+		ManagedByLabel: ManagedByValue,
+		ForLabel:       src.Name,
+	}
+	if src.Kind != KindServiceEntry {
+		labels[ForKindLabel] = src.Kind
+	}
+	for k, v := range additionalLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+func isPortIgnored(port *Port, labels map[string]string) bool {
+	for key, value := range labels {
+		if key == "skip-probe-for-port" && value == strconv.FormatUint(uint64(port.Number), 10) {
+			return true
+		}
+
+	}
+	return false
+}
+
+// generateTargets returns the targets of all hosts and ports that are not skipped by label or
+// annotation, one per path of the host. Modules are selected by annotation, then by the first
+// matching module rule, then by the module mappings, protocol mappings and default module of the
+// config. Paths set by annotation take precedence over the host mappings, which take precedence
+// over the paths of the Source. Ports in tlsPorts are probed with https on their upstream port
+// unless a host mapping sets the scheme or the port.
+func generateTargets(cfg *config.Config, log *logr.Logger, src *Source, overrides *Overrides, tlsPorts TLSPorts) (targets []probeTarget, labelsForModifications map[string]string) {
+	labelsForModifications = make(map[string]string)
+
+	replace := NewReplace(cfg, log)
+	for i := range src.Ports {
+		port := &src.Ports[i]
+		if isPortIgnored(port, src.Labels) || overrides.skipsPort(port) {
+			continue
+		}
+		for _, host := range port.hosts(src) {
+			if overrides.skipsHost(host) {
+				continue
+			}
+
+			upstream, tlsOriginated := tlsPorts[port.Number]
+			if !tlsOriginated {
+				upstream = port.Number
+			}
+			hostWithPort, scheme, hm := replace.modifiedHostname(host, port, upstream)
+			var hostMapping, moduleMapping string
+			if hm != nil {
+				hostMapping = hm.ReplacePattern
+			}
+			modifiedModule, rule := overrides.module(port.Number), ""
+			if modifiedModule == "" {
+				if mr := matchModuleRule(cfg, src, host, port); mr != nil {
+					modifiedModule, rule = mr.Module, mr.Name
+				}
+			}
+			if modifiedModule == "" {
+				var labelsFromModule map[string]string
+				var mm *config.ModuleMapping
+				modifiedModule, labelsFromModule, mm = replace.modifiedModule(host, port)
+				if mm != nil {
+					moduleMapping = mm.MatchPattern
+				}
+				for k, v := range labelsFromModule {
+					labelsForModifications[k] = v
+				}
+			}
+
+			if scheme == "" && (tlsOriginated || strings.ToUpper(port.Protocol) == "HTTPS") {
+				scheme = "https"
+			}
+			for _, target := range withPaths(hostWithPort, src.Paths[host], overrides.Path) {
+				if scheme != "" {
+					target = fmt.Sprintf("%s://%s", scheme, target)
+				}
+				targets = append(targets, probeTarget{
+					host:          host,
+					target:        target,
+					module:        modifiedModule,
+					rule:          rule,
+					hostMapping:   hostMapping,
+					moduleMapping: moduleMapping,
+				})
+			}
+		}
+	}
+	return targets, labelsForModifications
+}
+
+// withPaths returns host:port once per path. The path of the annotation replaces any path,
+// the paths of the Source are only used if the host mapping did not set one.
+func withPaths(hostWithPort string, paths []string, annotationPath string) []string {
+	if annotationPath != "" {
+		hostPort, _, _ := strings.Cut(hostWithPort, "/")
+		return []string{hostPort + annotationPath}
+	}
+	if strings.Contains(hostWithPort, "/") || len(paths) == 0 {
+		return []string{hostWithPort}
+	}
+	targets := make([]string, 0, len(paths))
+	for _, path := range paths {
+		targets = append(targets, hostWithPort+path)
+	}
+	return targets
+}
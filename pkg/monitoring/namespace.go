@@ -0,0 +1,45 @@
+package monitoring
+
+import (
+	"slices"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NamespaceFilter decides which namespaces' ServiceEntries are probed. A namespace is selected if
+// it is not in Config.ExcludedNamespaces, it is in Config.Namespaces if that is set and its labels
+// match Config.NamespaceSelector. An invalid selector selects no namespace, ParseConfig rejects those.
+type NamespaceFilter struct {
+	cfg      *config.Config
+	selector labels.Selector
+}
+
+func NewNamespaceFilter(cfg *config.Config) *NamespaceFilter {
+	selector := labels.Everything()
+	if !isEmpty(&cfg.NamespaceSelector) {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(&cfg.NamespaceSelector)
+		if err != nil {
+			selector = labels.Nothing()
+		}
+	}
+	return &NamespaceFilter{cfg: cfg, selector: selector}
+}
+
+// IsSelected reports whether ServiceEntries in the namespace with the given name and labels are probed.
+func (f *NamespaceFilter) IsSelected(name string, nsLabels map[string]string) bool {
+	if slices.Contains(f.cfg.ExcludedNamespaces, name) {
+		return false
+	}
+	if len(f.cfg.Namespaces) > 0 && !slices.Contains(f.cfg.Namespaces, name) {
+		return false
+	}
+	return f.selector.Matches(labels.Set(nsLabels))
+}
+
+// NeedsLabels reports whether IsSelected depends on the labels of the namespace.
+func (f *NamespaceFilter) NeedsLabels() bool {
+	return !isEmpty(&f.cfg.NamespaceSelector)
+}
@@ -0,0 +1,43 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceFilter(t *testing.T) {
+	teamSelector := metav1.LabelSelector{MatchLabels: map[string]string{"blackbox.io/probe": "enabled"}}
+	tests := []struct {
+		name      string
+		cfg       config.Config
+		namespace string
+		labels    map[string]string
+		want      bool
+	}{
+		{name: "no restrictions", namespace: "team-a", want: true},
+		{name: "allow list", cfg: config.Config{Namespaces: []string{"team-a"}}, namespace: "team-a", want: true},
+		{name: "not in allow list", cfg: config.Config{Namespaces: []string{"team-a"}}, namespace: "team-b", want: false},
+		{name: "deny list", cfg: config.Config{ExcludedNamespaces: []string{"kube-system"}}, namespace: "kube-system", want: false},
+		{
+			name:      "deny list wins over allow list",
+			cfg:       config.Config{Namespaces: []string{"team-a"}, ExcludedNamespaces: []string{"team-a"}},
+			namespace: "team-a",
+			want:      false,
+		},
+		{
+			name:      "selector matches",
+			cfg:       config.Config{NamespaceSelector: teamSelector},
+			namespace: "team-a",
+			labels:    map[string]string{"blackbox.io/probe": "enabled"},
+			want:      true,
+		},
+		{name: "selector does not match", cfg: config.Config{NamespaceSelector: teamSelector}, namespace: "team-a", want: false},
+	}
+	for _, tt := range tests {
+		if got := NewNamespaceFilter(&tt.cfg).IsSelected(tt.namespace, tt.labels); got != tt.want {
+			t.Errorf("%s: expected selected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
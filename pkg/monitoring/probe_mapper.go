@@ -0,0 +1,129 @@
+package monitoring
+
+import (
+	"github.com/go-logr/logr"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ProbeMapper generates a prometheus-operator Probe for a Source.
+// All hosts and ports end up as static targets of one Probe. Since a Probe has
+// only one module, targets with another module get it via relabeling.
+type ProbeMapper struct {
+	config *config.Config
+	log    *logr.Logger
+}
+
+func NewProbeMapper(cfg *config.Config, log *logr.Logger) *ProbeMapper {
+	return &ProbeMapper{
+		config: cfg,
+		log:    log,
+	}
+}
+
+func (pm *ProbeMapper) generateStaticConfig(src *Source, targets *Targets) *monitoringv1.ProbeTargetStaticConfig {
+
+	staticConfig := &monitoringv1.ProbeTargetStaticConfig{
+		Labels: map[string]string{
+			"namespace": src.Namespace,
+		},
+	}
+	for _, t := range targets.targets {
+		host := t.host
+		module := t.module
+		staticConfig.Targets = append(staticConfig.Targets, t.target)
+		staticConfig.RelabelConfigs = append(staticConfig.RelabelConfigs, monitoringv1.RelabelConfig{
+			SourceLabels: []monitoringv1.LabelName{"__param_target"},
+			Regex:        regexp.QuoteMeta(t.target),
+			Replacement:  &host,
+			TargetLabel:  "original_host",
+			Action:       "replace",
+		})
+		if t.rule != "" {
+			rule := t.rule
+			staticConfig.RelabelConfigs = append(staticConfig.RelabelConfigs, monitoringv1.RelabelConfig{
+				SourceLabels: []monitoringv1.LabelName{"__param_target"},
+				Regex:        regexp.QuoteMeta(t.target),
+				Replacement:  &rule,
+				TargetLabel:  ModuleRuleLabel,
+				Action:       "replace",
+			})
+		}
+		if t.module != pm.config.DefaultModule {
+			staticConfig.RelabelConfigs = append(staticConfig.RelabelConfigs, monitoringv1.RelabelConfig{
+				SourceLabels: []monitoringv1.LabelName{"__param_target"},
+				Regex:        regexp.QuoteMeta(t.target),
+				Replacement:  &module,
+				TargetLabel:  "__param_module",
+				Action:       "replace",
+			})
+		}
+	}
+
+	staticConfig.RelabelConfigs = append(staticConfig.RelabelConfigs, sourceRelabelings(src)...)
+	staticConfig.RelabelConfigs = append(staticConfig.RelabelConfigs,
+		monitoringv1.RelabelConfig{
+			SourceLabels: []monitoringv1.LabelName{"__param_target"},
+			TargetLabel:  "instance",
+			Action:       "replace",
+		},
+		monitoringv1.RelabelConfig{
+			SourceLabels: []monitoringv1.LabelName{"__param_module"},
+			TargetLabel:  "module",
+			Action:       "replace",
+		},
+	)
+	return staticConfig
+}
+
+func (pm *ProbeMapper) MapperForService(src *Source, tlsPorts TLSPorts) (*monitoringv1.Probe, error) {
+	return pm.mapTargets(src, GenerateTargets(pm.config, pm.log, src, tlsPorts))
+}
+
+func (pm *ProbeMapper) mapTargets(src *Source, targets *Targets) (*monitoringv1.Probe, error) {
+	name, err := getName(pm.config, src)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &targets.overrides
+	staticConfig := pm.generateStaticConfig(src, targets)
+	scheme := monitoringv1.Scheme(pm.config.Prober.Scheme)
+
+	probe := &monitoringv1.Probe{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: src.Namespace,
+			Labels:    getLabels(src, targets.labels),
+		},
+		Spec: monitoringv1.ProbeSpec{
+			ProberSpec: monitoringv1.ProberSpec{
+				URL:    pm.config.Prober.URL,
+				Scheme: &scheme,
+				Path:   pm.config.Prober.Path,
+			},
+			Module:        pm.config.DefaultModule,
+			Interval:      overrides.Interval,
+			ScrapeTimeout: overrides.ScrapeTimeout,
+			Targets: monitoringv1.ProbeTargets{
+				StaticConfig: staticConfig,
+			},
+		},
+	}
+	return probe, nil
+}
+
+// Map implements Mapper.
+func (pm *ProbeMapper) Map(src *Source, targets *Targets) (client.Object, error) {
+	probe, err := pm.mapTargets(src, targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(probe.Spec.Targets.StaticConfig.Targets) == 0 {
+		return nil, nil
+	}
+	return probe, nil
+}
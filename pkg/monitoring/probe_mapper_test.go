@@ -0,0 +1,57 @@
+package monitoring
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/test/utils"
+	"testing"
+)
+
+func TestProbes(t *testing.T) {
+	tests := []*struct {
+		name                 string
+		configFileName       string
+		serviceEntryFilename string
+		probeFilename        string
+	}{
+		{
+			name:                 "6 Probe with Module Overwrite",
+			configFileName:       "./testdata/6-config.yaml",
+			serviceEntryFilename: "./testdata/6-service-entry.yaml",
+			probeFilename:        "./testdata/6-probe.yaml",
+		},
+	}
+	for _, tt := range tests {
+		se, err := utils.LoadServiceEntry(tt.serviceEntryFilename)
+		if err != nil {
+			t.Errorf("%s: loadServiceEntry failed: '%v'", tt.name, err)
+		}
+		probe, err := utils.LoadProbe(tt.probeFilename)
+		if err != nil {
+			t.Errorf("%s: loadProbe failed: '%v'", tt.name, err)
+		}
+		cfg, err := config.LoadConfig(tt.configFileName)
+		if err != nil {
+			t.Errorf("%s: loadConfig failed: '%v'", tt.name, err)
+		}
+		logger := logr.Logger{}
+		mapper := NewMapper(cfg, &logger)
+		src := FromServiceEntry(se)
+		generated, err := mapper.Map(src, GenerateTargets(cfg, &logger, src, nil))
+		if err != nil {
+			t.Errorf("%s: Map failed: '%v'", tt.name, err)
+		}
+		generatedProbe, ok := generated.(*monitoringv1.Probe)
+		if !ok {
+			t.Fatalf("%s: expected a Probe, got %T", tt.name, generated)
+		}
+		generatedProbe.TypeMeta.Kind = probe.Kind
+		generatedProbe.TypeMeta.APIVersion = probe.APIVersion
+
+		if diff := cmp.Diff(probe, generatedProbe); diff != "" {
+			t.Errorf("%s: Probe mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
@@ -4,8 +4,7 @@ import (
 	"fmt"
 	"github.com/go-logr/logr"
 	"github.com/schmiddim/blackbox-operator/pkg/config"
-	"istio.io/api/networking/v1alpha3"
-	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -18,39 +17,102 @@ func NewReplace(cfg *config.Config, log *logr.Logger) *Replace {
 	return &Replace{cfg: cfg, log: log}
 }
 
-func (r *Replace) GetModifiedModule(host string, port *v1alpha3.ServicePort) (string, map[string]string) {
+func (r *Replace) GetModifiedModule(host string, port *Port) (string, map[string]string) {
+	module, labels, _ := r.modifiedModule(host, port)
+	return module, labels
+}
 
-	for _, mm := range r.cfg.ModuleMappings {
-		re := regexp.MustCompile(mm.MatchPattern)
-		if mm.Port == port.Number && re.MatchString(host) {
-			return mm.ReplaceModule, map[string]string{
-				"module_overwrite": mm.ReplaceModule,
-			}
-		}
+// modifiedModule is GetModifiedModule that also returns the applied module mapping, nil if
+// none matched.
+func (r *Replace) modifiedModule(host string, port *Port) (string, map[string]string, *config.ModuleMapping) {
+	if mm := r.moduleMapping(host, port); mm != nil {
+		return mm.ReplaceModule, map[string]string{
+			"module_overwrite": mm.ReplaceModule,
+		}, mm
 	}
 
-	for protocol, module := range r.cfg.ProtocolModuleMappings {
-		if strings.ToUpper(port.Protocol) == strings.ToUpper(protocol) {
-			return module, map[string]string{}
+	// sorted, so the result does not depend on the map order if protocols differ only in case
+	protocols := make([]string, 0, len(r.cfg.ProtocolModuleMappings))
+	for protocol := range r.cfg.ProtocolModuleMappings {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	for _, protocol := range protocols {
+		if strings.EqualFold(port.Protocol, protocol) {
+			return r.cfg.ProtocolModuleMappings[protocol], map[string]string{}, nil
 		}
 	}
 
 	r.log.Info(fmt.Sprintf("No module for protocol %s - configuring Default (%s)", port.Protocol, r.cfg.DefaultModule))
-	return r.cfg.DefaultModule, map[string]string{}
-}
-
-func (r *Replace) GetModifiedHostname(host string, port *v1alpha3.ServicePort) string {
-	for _, hm := range r.cfg.HostMappings {
-		re := regexp.MustCompile(hm.ReplacePattern)
-		if hm.Port == port.Number && re.MatchString(host) {
-			modified := strings.Replace(hm.ReplaceWith, "*", host[len(hm.ReplacePattern):], 1)
-			parts := strings.SplitN(modified, "/", 2) // Teilt in maximal zwei Teile
-			if len(parts) == 2 {
-				formated := fmt.Sprintf("%s:%d/%s", parts[0], port.Number, parts[1])
-				return formated
-			}
-			return fmt.Sprintf("%s:%d", modified, port.Number)
+	return r.cfg.DefaultModule, map[string]string{}, nil
+}
+
+// GetModifiedHostname applies the first matching host mapping for the port and returns
+// host:targetPort with an optional path, and the scheme of the mapping, empty if it sets none.
+// The TargetPort of the mapping overrides targetPort.
+func (r *Replace) GetModifiedHostname(host string, port *Port, targetPort uint32) (string, string) {
+	hostWithPort, scheme, _ := r.modifiedHostname(host, port, targetPort)
+	return hostWithPort, scheme
+}
+
+// modifiedHostname is GetModifiedHostname that also returns the applied host mapping, nil if
+// none matched.
+func (r *Replace) modifiedHostname(host string, port *Port, targetPort uint32) (string, string, *config.HostMapping) {
+	hm, loc := r.hostMapping(host, port)
+	if hm == nil {
+		return fmt.Sprintf("%s:%d", host, targetPort), "", nil
+	}
+	var modified string
+	if hm.IsCompatibilityMode() {
+		modified = strings.Replace(hm.ReplaceWith, "*", host[loc[1]:], 1)
+	} else {
+		modified = hm.ReplaceRegexp().ReplaceAllString(host, hm.ReplaceWith)
+	}
+	modifiedHost, path, hasPath := strings.Cut(modified, "/")
+	if hasPath {
+		path = "/" + path
+	}
+	if hm.Path != "" {
+		path = hm.Path
+	}
+	if hm.TargetPort != 0 {
+		targetPort = hm.TargetPort
+	}
+	return fmt.Sprintf("%s:%d%s", modifiedHost, targetPort, path), hm.Scheme, hm
+}
+
+// moduleMapping returns the first module mapping matching host and port, nil if none does.
+func (r *Replace) moduleMapping(host string, port *Port) *config.ModuleMapping {
+	for i := range r.cfg.ModuleMappings {
+		mm := &r.cfg.ModuleMappings[i]
+		re := mm.MatchRegexp()
+		if re == nil {
+			r.log.Info("Skipping module mapping with invalid matchPattern", "matchPattern", mm.MatchPattern)
+			continue
+		}
+		if mm.Port == port.Number && re.MatchString(host) {
+			return mm
+		}
+	}
+	return nil
+}
+
+// hostMapping returns the first host mapping matching host and port and the location of the
+// match in host, nil if none does.
+func (r *Replace) hostMapping(host string, port *Port) (*config.HostMapping, []int) {
+	for i := range r.cfg.HostMappings {
+		hm := &r.cfg.HostMappings[i]
+		re := hm.ReplaceRegexp()
+		if re == nil {
+			r.log.Info("Skipping host mapping with invalid replacePattern", "replacePattern", hm.ReplacePattern)
+			continue
+		}
+		if hm.Port != port.Number {
+			continue
+		}
+		if loc := re.FindStringIndex(host); loc != nil {
+			return hm, loc
 		}
 	}
-	return fmt.Sprintf("%s:%d", host, port.Number)
+	return nil, nil
 }
@@ -0,0 +1,72 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+)
+
+func TestGetModifiedHostname(t *testing.T) {
+	port := &Port{Name: "https", Number: 443, Protocol: "HTTPS"}
+	tests := []struct {
+		name       string
+		mapping    config.HostMapping
+		host       string
+		wantTarget string
+		wantScheme string
+	}{
+		{
+			name:       "no match",
+			mapping:    config.HostMapping{Port: 443, ReplacePattern: `^api\.`, ReplaceWith: "health.$0"},
+			host:       "www.example.com",
+			wantTarget: "www.example.com:443",
+		},
+		{
+			name:       "other port",
+			mapping:    config.HostMapping{Port: 8443, ReplacePattern: `example`, ReplaceWith: "other"},
+			host:       "www.example.com",
+			wantTarget: "www.example.com:443",
+		},
+		{
+			name:       "capture groups",
+			mapping:    config.HostMapping{Port: 443, ReplacePattern: `^dex\.sys\.([a-z]+)\.(.+)$`, ReplaceWith: "dex.$1.$2/healthz"},
+			host:       "dex.sys.core.example-cloud.de",
+			wantTarget: "dex.core.example-cloud.de:443/healthz",
+		},
+		{
+			name:       "literal replacement",
+			mapping:    config.HostMapping{Port: 443, ReplacePattern: `foo.host.example.de`, ReplaceWith: "foo.host.example.com"},
+			host:       "foo.host.example.de",
+			wantTarget: "foo.host.example.com:443",
+		},
+		{
+			name:       "compatibility mode",
+			mapping:    config.HostMapping{Port: 443, ReplacePattern: `dex.sys.`, ReplaceWith: "dex.sys.*/healthz"},
+			host:       "dex.sys.foo.example.de",
+			wantTarget: "dex.sys.foo.example.de:443/healthz",
+		},
+		{
+			name:       "compatibility mode with metacharacters",
+			mapping:    config.HostMapping{Port: 443, ReplacePattern: `^dex\.sys\.`, ReplaceWith: "probe.*"},
+			host:       "dex.sys.foo.example.de",
+			wantTarget: "probe.foo.example.de:443",
+		},
+		{
+			name: "scheme, target port and path",
+			mapping: config.HostMapping{Port: 443, ReplacePattern: `^(.+)\.example\.de$`, ReplaceWith: "$1.internal/ignored",
+				Scheme: "http", TargetPort: 8080, Path: "/ready"},
+			host:       "api.example.de",
+			wantTarget: "api.internal:8080/ready",
+			wantScheme: "http",
+		},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{HostMappings: []config.HostMapping{tt.mapping}}
+		logger := logr.Discard()
+		target, scheme := NewReplace(cfg, &logger).GetModifiedHostname(tt.host, port, port.Number)
+		if target != tt.wantTarget || scheme != tt.wantScheme {
+			t.Errorf("%s: expected %q with scheme %q, got %q with scheme %q", tt.name, tt.wantTarget, tt.wantScheme, target, scheme)
+		}
+	}
+}
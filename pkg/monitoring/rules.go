@@ -0,0 +1,59 @@
+package monitoring
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ModuleRuleLabel holds the name of the module rule that selected the module of a target.
+const ModuleRuleLabel = "module_rule"
+
+// matchModuleRule returns the first rule of the config matching the host and port of the
+// Source, nil if there is none. Rules with an invalid host pattern or selector never match.
+func matchModuleRule(cfg *config.Config, src *Source, host string, port *Port) *config.ModuleRule {
+	for i := range cfg.ModuleRules {
+		if mr := &cfg.ModuleRules[i]; ruleMatches(mr, src, host, port) {
+			return mr
+		}
+	}
+	return nil
+}
+
+func ruleMatches(mr *config.ModuleRule, src *Source, host string, port *Port) bool {
+	if mr.HostPattern != "" {
+		re := mr.HostRegexp()
+		if re == nil || !re.MatchString(host) {
+			return false
+		}
+	}
+	if !mr.MatchesPort(port.Number) {
+		return false
+	}
+	if len(mr.PortNames) > 0 && !slices.Contains(mr.PortNames, port.Name) {
+		return false
+	}
+	if len(mr.Protocols) > 0 && !containsFold(mr.Protocols, port.Protocol) {
+		return false
+	}
+	if mr.Location != "" && mr.Location != src.Location {
+		return false
+	}
+	if mr.Resolution != "" && mr.Resolution != src.Resolution {
+		return false
+	}
+	if !isEmpty(&mr.Selector) {
+		selector, err := metav1.LabelSelectorAsSelector(&mr.Selector)
+		if err != nil || !selector.Matches(labels.Set(src.Labels)) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, value) })
+}
@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestModuleRules(t *testing.T) {
+	cfg := &config.Config{
+		DefaultModule:          "http_2xx",
+		ProtocolModuleMappings: map[string]string{"TCP": "tcp_connect"},
+		ModuleRules: []config.ModuleRule{
+			{Name: "internal", Module: "http_internal", Location: "MESH_INTERNAL"},
+			{Name: "admin-ports", Module: "http_admin", Ports: []string{"9000-9099"}, Protocols: []string{"http"}},
+			{Name: "grpc", Module: "grpc", PortNames: []string{"grpc"}},
+			{Name: "team-a-api", Module: "http_api", HostPattern: `^api\.`, Resolution: "DNS",
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}},
+			{Name: "catch-all-api", Module: "http_api_other", HostPattern: `^api\.`},
+		},
+	}
+	tests := []struct {
+		name       string
+		host       string
+		port       *v1alpha3.ServicePort
+		location   v1alpha3.ServiceEntry_Location
+		resolution v1alpha3.ServiceEntry_Resolution
+		labels     map[string]string
+		wantModule string
+		wantRule   string
+	}{
+		{name: "location", host: "www.example.com", port: &v1alpha3.ServicePort{Number: 443, Protocol: "HTTPS"},
+			location: v1alpha3.ServiceEntry_MESH_INTERNAL, wantModule: "http_internal", wantRule: "internal"},
+		{name: "port range and protocol", host: "www.example.com", port: &v1alpha3.ServicePort{Number: 9042, Protocol: "HTTP"},
+			wantModule: "http_admin", wantRule: "admin-ports"},
+		{name: "port range with other protocol", host: "www.example.com", port: &v1alpha3.ServicePort{Number: 9042, Protocol: "TCP"},
+			wantModule: "tcp_connect"},
+		{name: "port name", host: "www.example.com", port: &v1alpha3.ServicePort{Name: "grpc", Number: 443, Protocol: "GRPC"},
+			wantModule: "grpc", wantRule: "grpc"},
+		{name: "host, resolution and labels", host: "api.example.com", port: &v1alpha3.ServicePort{Number: 443, Protocol: "HTTPS"},
+			resolution: v1alpha3.ServiceEntry_DNS, labels: map[string]string{"team": "a"}, wantModule: "http_api", wantRule: "team-a-api"},
+		{name: "first match wins", host: "api.example.com", port: &v1alpha3.ServicePort{Number: 443, Protocol: "HTTPS"},
+			resolution: v1alpha3.ServiceEntry_DNS, labels: map[string]string{"team": "b"}, wantModule: "http_api_other", wantRule: "catch-all-api"},
+		{name: "no rule", host: "www.example.com", port: &v1alpha3.ServicePort{Number: 443, Protocol: "HTTPS"},
+			wantModule: "http_2xx"},
+	}
+	for _, tt := range tests {
+		se := &istioNetworking.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{Name: "se", Labels: tt.labels},
+			Spec: v1alpha3.ServiceEntry{
+				Hosts:      []string{tt.host},
+				Ports:      []*v1alpha3.ServicePort{tt.port},
+				Location:   tt.location,
+				Resolution: tt.resolution,
+			},
+		}
+		logger := logr.Discard()
+		targets, _ := generateTargets(cfg, &logger, FromServiceEntry(se), &Overrides{}, nil)
+		if len(targets) != 1 {
+			t.Fatalf("%s: expected 1 target, got %d", tt.name, len(targets))
+		}
+		if targets[0].module != tt.wantModule || targets[0].rule != tt.wantRule {
+			t.Errorf("%s: expected module %s by rule %q, got %s by rule %q", tt.name, tt.wantModule, tt.wantRule, targets[0].module, targets[0].rule)
+		}
+	}
+}
+
+func TestModuleRuleLabel(t *testing.T) {
+	cfg := getCfg()
+	cfg.ModuleRules = []config.ModuleRule{{Name: "https", Module: "http_tls", Protocols: []string{"HTTPS"}}}
+	logger := logr.Discard()
+	se := &istioNetworking.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{Name: "se"},
+		Spec: v1alpha3.ServiceEntry{
+			Hosts: []string{"www.example.com"},
+			Ports: []*v1alpha3.ServicePort{{Number: 443, Protocol: "HTTPS"}, {Number: 80, Protocol: "HTTP"}},
+		},
+	}
+	sm, err := NewServiceMonitorMapper(&cfg, &logger).MapperForService(FromServiceEntry(se), nil)
+	if err != nil {
+		t.Fatalf("MapperForService failed: '%v'", err)
+	}
+	for i, want := range []string{"https", ""} {
+		got := ""
+		for _, relabel := range sm.Spec.Endpoints[i].RelabelConfigs {
+			if relabel.TargetLabel == ModuleRuleLabel {
+				got = *relabel.Replacement
+			}
+		}
+		if got != want {
+			t.Errorf("endpoint %d: expected rule label %q, got %q", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package monitoring
+
+import (
+	"github.com/go-logr/logr"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ScrapeConfigMapper generates a prometheus-operator ScrapeConfig for a Source.
+// Every target gets its own static config carrying the module and the original
+// host as labels. The blackbox exporter is set as __address__ via relabeling,
+// so no Service of the exporter has to be selected.
+type ScrapeConfigMapper struct {
+	config *config.Config
+	log    *logr.Logger
+}
+
+func NewScrapeConfigMapper(cfg *config.Config, log *logr.Logger) *ScrapeConfigMapper {
+	return &ScrapeConfigMapper{
+		config: cfg,
+		log:    log,
+	}
+}
+
+func (scm *ScrapeConfigMapper) generateStaticConfigs(src *Source, targets *Targets) []monitoringv1alpha1.StaticConfig {
+	var staticConfigs []monitoringv1alpha1.StaticConfig
+	for _, t := range targets.targets {
+		labels := map[string]string{
+			"__param_module": t.module,
+			"original_host":  t.host,
+			"namespace":      src.Namespace,
+		}
+		if t.rule != "" {
+			labels[ModuleRuleLabel] = t.rule
+		}
+		staticConfigs = append(staticConfigs, monitoringv1alpha1.StaticConfig{
+			Targets: []monitoringv1alpha1.Target{monitoringv1alpha1.Target(t.target)},
+			Labels:  labels,
+		})
+	}
+	return staticConfigs
+}
+
+func (scm *ScrapeConfigMapper) MapperForService(src *Source, tlsPorts TLSPorts) (*monitoringv1alpha1.ScrapeConfig, error) {
+	return scm.mapTargets(src, GenerateTargets(scm.config, scm.log, src, tlsPorts))
+}
+
+func (scm *ScrapeConfigMapper) mapTargets(src *Source, targets *Targets) (*monitoringv1alpha1.ScrapeConfig, error) {
+	name, err := getName(scm.config, src)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &targets.overrides
+	staticConfigs := scm.generateStaticConfigs(src, targets)
+	proberURL := scm.config.Prober.URL
+	metricsPath := scm.config.Prober.Path
+	scheme := monitoringv1.Scheme(scm.config.Prober.Scheme)
+	interval := overrides.Interval
+	scrapeTimeout := overrides.ScrapeTimeout
+	relabelings := []monitoringv1.RelabelConfig{
+		{
+			SourceLabels: []monitoringv1.LabelName{"__address__"},
+			TargetLabel:  "__param_target",
+			Action:       "replace",
+		},
+		{
+			SourceLabels: []monitoringv1.LabelName{"__param_target"},
+			TargetLabel:  "instance",
+			Action:       "replace",
+		},
+		{
+			SourceLabels: []monitoringv1.LabelName{"__param_module"},
+			TargetLabel:  "module",
+			Action:       "replace",
+		},
+	}
+	relabelings = append(relabelings, sourceRelabelings(src)...)
+
+	sc := &monitoringv1alpha1.ScrapeConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: src.Namespace,
+			Labels:    getLabels(src, targets.labels),
+		},
+		Spec: monitoringv1alpha1.ScrapeConfigSpec{
+			StaticConfigs:  staticConfigs,
+			MetricsPath:    &metricsPath,
+			Scheme:         &scheme,
+			ScrapeInterval: &interval,
+			ScrapeTimeout:  &scrapeTimeout,
+			Params: map[string][]string{
+				"module": {scm.config.DefaultModule},
+			},
+			RelabelConfigs: append(relabelings, monitoringv1.RelabelConfig{
+				Replacement: &proberURL,
+				TargetLabel: "__address__",
+				Action:      "replace",
+			}),
+		},
+	}
+	return sc, nil
+}
+
+// Map implements Mapper.
+func (scm *ScrapeConfigMapper) Map(src *Source, targets *Targets) (client.Object, error) {
+	sc, err := scm.mapTargets(src, targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(sc.Spec.StaticConfigs) == 0 {
+		return nil, nil
+	}
+	return sc, nil
+}
@@ -0,0 +1,57 @@
+package monitoring
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"github.com/schmiddim/blackbox-operator/test/utils"
+	"testing"
+)
+
+func TestScrapeConfigs(t *testing.T) {
+	tests := []*struct {
+		name                 string
+		configFileName       string
+		serviceEntryFilename string
+		scrapeConfigFilename string
+	}{
+		{
+			name:                 "7 ScrapeConfig with Module Overwrite",
+			configFileName:       "./testdata/7-config.yaml",
+			serviceEntryFilename: "./testdata/7-service-entry.yaml",
+			scrapeConfigFilename: "./testdata/7-scrape-config.yaml",
+		},
+	}
+	for _, tt := range tests {
+		se, err := utils.LoadServiceEntry(tt.serviceEntryFilename)
+		if err != nil {
+			t.Errorf("%s: loadServiceEntry failed: '%v'", tt.name, err)
+		}
+		scrapeConfig, err := utils.LoadScrapeConfig(tt.scrapeConfigFilename)
+		if err != nil {
+			t.Errorf("%s: loadScrapeConfig failed: '%v'", tt.name, err)
+		}
+		cfg, err := config.LoadConfig(tt.configFileName)
+		if err != nil {
+			t.Errorf("%s: loadConfig failed: '%v'", tt.name, err)
+		}
+		logger := logr.Logger{}
+		mapper := NewMapper(cfg, &logger)
+		src := FromServiceEntry(se)
+		generated, err := mapper.Map(src, GenerateTargets(cfg, &logger, src, nil))
+		if err != nil {
+			t.Errorf("%s: Map failed: '%v'", tt.name, err)
+		}
+		generatedScrapeConfig, ok := generated.(*monitoringv1alpha1.ScrapeConfig)
+		if !ok {
+			t.Fatalf("%s: expected a ScrapeConfig, got %T", tt.name, generated)
+		}
+		generatedScrapeConfig.TypeMeta.Kind = scrapeConfig.Kind
+		generatedScrapeConfig.TypeMeta.APIVersion = scrapeConfig.APIVersion
+
+		if diff := cmp.Diff(scrapeConfig, generatedScrapeConfig); diff != "" {
+			t.Errorf("%s: ScrapeConfig mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
@@ -1,16 +1,11 @@
 package monitoring
 
 import (
-	"errors"
-	"fmt"
 	"github.com/go-logr/logr"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/schmiddim/blackbox-operator/pkg/config"
-	"istio.io/api/networking/v1alpha3"
-	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"strconv"
-	"strings"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type ServiceMonitorMapper struct {
@@ -25,110 +20,87 @@ func NewServiceMonitorMapper(cfg *config.Config, log *logr.Logger) *ServiceMonit
 	}
 }
 
-func (smm *ServiceMonitorMapper) GetNameForServiceMonitor(ServiceEntryName string) (string, error) {
-
-	count := strings.Count(smm.config.ServiceMonitorNamingPattern, "%s")
-	if count != 1 {
-		return "", errors.New("ServiceMonitorNamingPattern must contain 1 %s")
-	}
-	name := fmt.Sprintf(smm.config.ServiceMonitorNamingPattern, ServiceEntryName)
-	return name, nil
+// GetNameForServiceMonitor renders Config.ServiceMonitorNamingPattern for the Source.
+func (smm *ServiceMonitorMapper) GetNameForServiceMonitor(src *Source) (string, error) {
+	return getName(smm.config, src)
 }
 
-func (smm *ServiceMonitorMapper) isPortIgnored(port *v1alpha3.ServicePort, labels map[string]string) bool {
-	for key, value := range labels {
-		if key == "skip-probe-for-port" && value == strconv.FormatUint(uint64(port.Number), 10) {
-			return true
+func (smm *ServiceMonitorMapper) generateEndpoints(src *Source, targets *Targets) (endpoints []monitoringv1.Endpoint) {
+	overrides := &targets.overrides
+	for _, t := range targets.targets {
+		host := t.host
+		scheme := monitoringv1.Scheme("http")
+		relabelings := []monitoringv1.RelabelConfig{
+			{
+				Replacement: &host,
+				TargetLabel: "original_host",
+				Action:      "replace",
+			},
 		}
-
-	}
-	return false
-}
-func (smm *ServiceMonitorMapper) generateEndpoints(seName string, hosts []string, ports []*v1alpha3.ServicePort, labels map[string]string) (endpoints []monitoringv1.Endpoint, labelsForModifications map[string]string) {
-	labelsForModifications = make(map[string]string)
-
-	replace := NewReplace(smm.config, smm.log)
-	for _, port := range ports {
-		if smm.isPortIgnored(port, labels) {
-			continue
+		relabelings = append(relabelings, sourceRelabelings(src)...)
+		relabelings = append(relabelings,
+			monitoringv1.RelabelConfig{
+				SourceLabels: []monitoringv1.LabelName{"__param_target"},
+				TargetLabel:  "instance",
+				Action:       "replace",
+			},
+			monitoringv1.RelabelConfig{
+				SourceLabels: []monitoringv1.LabelName{"__param_module"},
+				TargetLabel:  "module",
+				Action:       "replace",
+			},
+			monitoringv1.RelabelConfig{
+				Action: "labeldrop",
+				Regex:  "pod|service|container",
+			},
+			monitoringv1.RelabelConfig{
+				SourceLabels: []monitoringv1.LabelName{"__meta_kubernetes_namespace"},
+				TargetLabel:  "namespace",
+				Action:       "replace",
+			},
+		)
+		e := monitoringv1.Endpoint{
+			Interval:      overrides.Interval,
+			Port:          "http",
+			Scheme:        &scheme,
+			Path:          "/probe",
+			ScrapeTimeout: overrides.ScrapeTimeout,
+			Params: map[string][]string{
+				"module": {t.module},
+				"target": {t.target},
+			},
+			RelabelConfigs: relabelings,
 		}
-		for _, host := range hosts {
-
-			hostWithPort := replace.GetModifiedHostname(host, port)
-			modifiedModule, labelsFromModule := replace.GetModifiedModule(host, port)
-			for k, v := range labelsFromModule {
-				labelsForModifications[k] = v
-			}
-
-			if strings.ToUpper(port.GetProtocol()) == "HTTPS" {
-				hostWithPort = fmt.Sprintf("https://%s", hostWithPort)
-			}
-			e := monitoringv1.Endpoint{
-				Interval:      smm.config.Interval,
-				Port:          "http",
-				Scheme:        "http",
-				Path:          "/probe",
-				ScrapeTimeout: smm.config.ScrapeTimeout,
-				Params: map[string][]string{
-					"module": {modifiedModule},
-					"target": {hostWithPort},
-				},
-				RelabelConfigs: []monitoringv1.RelabelConfig{
-					{
-						Replacement: &host,
-						TargetLabel: "original_host",
-						Action:      "replace",
-					},
-
-					{
-						Replacement: &seName,
-						TargetLabel: "for",
-						Action:      "replace",
-					},
-					{
-						SourceLabels: []monitoringv1.LabelName{"__param_target"},
-						TargetLabel:  "instance",
-						Action:       "replace",
-					},
-					{
-						SourceLabels: []monitoringv1.LabelName{"__param_module"},
-						TargetLabel:  "module",
-						Action:       "replace",
-					},
-					{
-						Action: "labeldrop",
-						Regex:  "pod|service|container",
-					},
-					{
-						SourceLabels: []monitoringv1.LabelName{"__meta_kubernetes_namespace"},
-						TargetLabel:  "namespace",
-						Action:       "replace",
-					},
-				},
-			}
-			endpoints = append(endpoints, e)
-
+		if t.rule != "" {
+			rule := t.rule
+			e.RelabelConfigs = append(e.RelabelConfigs, monitoringv1.RelabelConfig{
+				Replacement: &rule,
+				TargetLabel: ModuleRuleLabel,
+				Action:      "replace",
+			})
 		}
+		endpoints = append(endpoints, e)
 	}
-	return endpoints, labelsForModifications
+	return endpoints
 }
 
-func (smm *ServiceMonitorMapper) MapperForService(se *istioNetworking.ServiceEntry) *monitoringv1.ServiceMonitor {
+func (smm *ServiceMonitorMapper) MapperForService(src *Source, tlsPorts TLSPorts) (*monitoringv1.ServiceMonitor, error) {
+	return smm.mapTargets(src, GenerateTargets(smm.config, smm.log, src, tlsPorts))
+}
 
-	endpoints, additionalLabels := smm.generateEndpoints(se.Name, se.Spec.Hosts, se.Spec.Ports, se.ObjectMeta.Labels)
-	labels := map[string]string{
-		"managed-by": "blackbox-operator",
-		"for":        se.Name,
-	}
-	for k, v := range additionalLabels {
-		labels[k] = v
+func (smm *ServiceMonitorMapper) mapTargets(src *Source, targets *Targets) (*monitoringv1.ServiceMonitor, error) {
+	name, err := smm.GetNameForServiceMonitor(src)
+	if err != nil {
+		return nil, err
 	}
 
+	endpoints := smm.generateEndpoints(src, targets)
+
 	sm := &monitoringv1.ServiceMonitor{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "sm-" + se.Name,
-			Namespace: se.Namespace,
-			Labels:    labels,
+			Name:      name,
+			Namespace: src.Namespace,
+			Labels:    getLabels(src, targets.labels),
 		},
 		Spec: monitoringv1.ServiceMonitorSpec{
 			NamespaceSelector: monitoringv1.NamespaceSelector{
@@ -140,5 +112,17 @@ func (smm *ServiceMonitorMapper) MapperForService(se *istioNetworking.ServiceEnt
 		},
 	}
 
-	return sm
+	return sm, nil
+}
+
+// Map implements Mapper.
+func (smm *ServiceMonitorMapper) Map(src *Source, targets *Targets) (client.Object, error) {
+	sm, err := smm.mapTargets(src, targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(sm.Spec.Endpoints) == 0 {
+		return nil, nil
+	}
+	return sm, nil
 }
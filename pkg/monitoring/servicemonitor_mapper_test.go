@@ -5,6 +5,8 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/schmiddim/blackbox-operator/pkg/config"
 	"github.com/schmiddim/blackbox-operator/test/utils"
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"testing"
 )
@@ -60,6 +62,18 @@ func TestServiceEntries(t *testing.T) {
 			serviceEntryFilename: "./testdata/5-service-entry.yaml",
 			serviceEntryMonitor:  "./testdata/5-service-monitor.yaml",
 		},
+		{
+			name:                 "8 Annotation Overrides",
+			configFileName:       "./testdata/8-config.yaml",
+			serviceEntryFilename: "./testdata/8-service-entry.yaml",
+			serviceEntryMonitor:  "./testdata/8-service-monitor.yaml",
+		},
+		{
+			name:                 "9 Skip Ports and Hosts",
+			configFileName:       "./testdata/9-config.yaml",
+			serviceEntryFilename: "./testdata/9-service-entry.yaml",
+			serviceEntryMonitor:  "./testdata/9-service-monitor.yaml",
+		},
 	}
 	for _, tt := range tests {
 		se, err := utils.LoadServiceEntry(tt.serviceEntryFilename)
@@ -80,7 +94,10 @@ func TestServiceEntries(t *testing.T) {
 			config: cfg,
 			log:    &(logr.Logger{}),
 		}
-		generatedSm := smm.MapperForService(se)
+		generatedSm, err := smm.MapperForService(FromServiceEntry(se), nil)
+		if err != nil {
+			t.Errorf("%s: MapperForService failed: '%v'", tt.name, err)
+		}
 		generatedSm.TypeMeta.Kind = serviceMonitor.Kind
 		generatedSm.TypeMeta.Kind = serviceMonitor.Kind
 		generatedSm.TypeMeta.APIVersion = serviceMonitor.APIVersion
@@ -93,21 +110,80 @@ func TestServiceEntries(t *testing.T) {
 
 }
 
-// @todo move to file  tt
+func TestEveryTargetSkipped(t *testing.T) {
+	cfg := getCfg()
+	logger := logr.Logger{}
+	se := &istioNetworking.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hansi",
+			Namespace:   "team-a",
+			Annotations: map[string]string{SkipPortsAnnotation: "443"},
+		},
+		Spec: v1alpha3.ServiceEntry{
+			Hosts: []string{"www.example.com"},
+			Ports: []*v1alpha3.ServicePort{{Name: "https", Number: 443, Protocol: "HTTPS"}},
+		},
+	}
+	for _, output := range []string{config.OutputServiceMonitor, config.OutputProbe, config.OutputScrapeConfig} {
+		cfg.Output = output
+		src := FromServiceEntry(se)
+		obj, err := NewMapper(&cfg, &logger).Map(src, GenerateTargets(&cfg, &logger, src, nil))
+		if err != nil {
+			t.Fatalf("%s: Map failed: '%v'", output, err)
+		}
+		if obj != nil {
+			t.Errorf("%s: expected no object, got %T", output, obj)
+		}
+	}
+}
+
 func TestNamingPattern(t *testing.T) {
 	cfg := getCfg()
 	logger := logr.Logger{}
 	mapper := NewServiceMonitorMapper(&cfg, &logger)
+	se := &istioNetworking.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hansi",
+			Namespace: "team-a",
+			Labels:    map[string]string{"team": "blue"},
+		},
+		Spec: v1alpha3.ServiceEntry{Hosts: []string{"www.example.com"}},
+	}
 
-	got, _ := mapper.GetNameForServiceMonitor("hansi")
-	want := "buah-hansi"
-	if got != want {
-		t.Errorf("expected %s, got %s", want, got)
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{pattern: cfg.ServiceMonitorNamingPattern, want: "buah-hansi"},
+		{pattern: `{{ .Namespace }}-{{ index .Labels "team" }}-{{ .Host }}-{{ .Name }}`, want: "team-a-blue-www.example.com-hansi"},
+		{pattern: "invalid", wantErr: true},
+		{pattern: "%s-%s", wantErr: true},
+		{pattern: "{{ .Unknown }}", wantErr: true},
+		{pattern: "sm_%s", wantErr: true},
 	}
+	for _, tt := range tests {
+		cfg.ServiceMonitorNamingPattern = tt.pattern
+		got, err := mapper.GetNameForServiceMonitor(FromServiceEntry(se))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", tt.pattern, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error '%v'", tt.pattern, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.pattern, tt.want, got)
+		}
 
-	cfg.ServiceMonitorNamingPattern = "invalid"
-	_, err := mapper.GetNameForServiceMonitor("hansi")
-	if err == nil {
-		t.Errorf("expected %s, got %s", err, "nil")
+		sm, err := mapper.MapperForService(FromServiceEntry(se), nil)
+		if err != nil {
+			t.Fatalf("%q: MapperForService failed: '%v'", tt.pattern, err)
+		}
+		if sm.Name != tt.want {
+			t.Errorf("%q: expected ServiceMonitor name %s, got %s", tt.pattern, tt.want, sm.Name)
+		}
 	}
 }
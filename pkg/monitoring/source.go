@@ -0,0 +1,125 @@
+package monitoring
+
+import (
+	"fmt"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KindServiceEntry is the kind of Sources created from an Istio ServiceEntry.
+	KindServiceEntry = "ServiceEntry"
+	// KindService is the kind of Sources created from an ExternalName Service.
+	KindService = "Service"
+	// ForKindLabel holds the kind of the Source an object was generated for. It is only set if
+	// the Source is not a ServiceEntry, so objects generated before it was introduced keep their labels.
+	ForKindLabel = "for-kind"
+	// SeriesNamespaceLabel and SeriesKindLabel are set on the series of the targets together with
+	// "for", so series of Sources with the same name in other namespaces or of other kinds are
+	// told apart. The namespace label of a series is the one of the exporter.
+	SeriesNamespaceLabel = "for_namespace"
+	SeriesKindLabel      = "for_kind"
+)
+
+// sourceRelabelings returns the relabelings that set the labels of the Source on the series of
+// its targets.
+func sourceRelabelings(src *Source) []monitoringv1.RelabelConfig {
+	name, namespace, kind := src.Name, src.Namespace, src.Kind
+	return []monitoringv1.RelabelConfig{
+		{Replacement: &name, TargetLabel: "for", Action: "replace"},
+		{Replacement: &namespace, TargetLabel: SeriesNamespaceLabel, Action: "replace"},
+		{Replacement: &kind, TargetLabel: SeriesKindLabel, Action: "replace"},
+	}
+}
+
+// SourceSelector returns the label matchers, without braces, of the series of the targets of
+// the Source with the kind, namespace and name.
+func SourceSelector(kind, namespace, name string) string {
+	return fmt.Sprintf("for=%q,%s=%q,%s=%q", name, SeriesNamespaceLabel, namespace, SeriesKindLabel, kind)
+}
+
+// Source is the input of the mappers: the hosts and ports of a ServiceEntry or an ExternalName Service.
+type Source struct {
+	metav1.ObjectMeta
+	// Kind of the object the Source was created from.
+	Kind  string
+	Hosts []string
+	Ports []Port
+	// Location and Resolution of a ServiceEntry, empty for other kinds.
+	Location   string
+	Resolution string
+	// Paths probed per host, the host itself is probed if it has none.
+	Paths map[string][]string
+}
+
+// Port is a port of a Source.
+type Port struct {
+	Name     string
+	Number   uint32
+	Protocol string
+	// TargetPort is the upstream port, zero if it is the same as Number.
+	TargetPort uint32
+	// Hosts served on the port, all hosts of the Source if empty.
+	Hosts []string
+}
+
+// hosts returns the hosts of the Source served on the port.
+func (p *Port) hosts(src *Source) []string {
+	if len(p.Hosts) > 0 {
+		return p.Hosts
+	}
+	return src.Hosts
+}
+
+// FromServiceEntry returns the Source of a ServiceEntry.
+func FromServiceEntry(se *istioNetworking.ServiceEntry) *Source {
+	src := &Source{
+		ObjectMeta: se.ObjectMeta,
+		Kind:       KindServiceEntry,
+		Hosts:      se.Spec.Hosts,
+		Location:   se.Spec.Location.String(),
+		Resolution: se.Spec.Resolution.String(),
+	}
+	for _, port := range se.Spec.Ports {
+		src.Ports = append(src.Ports, Port{
+			Name:       port.GetName(),
+			Number:     port.GetNumber(),
+			Protocol:   port.GetProtocol(),
+			TargetPort: port.GetTargetPort(),
+		})
+	}
+	return src
+}
+
+// FromService returns the Source of an ExternalName Service, its host is the externalName.
+// The protocol of a port is its appProtocol if set, the targetPort is ignored as clients
+// connect to the externalName on the port directly.
+func FromService(svc *corev1.Service) *Source {
+	src := &Source{
+		ObjectMeta: svc.ObjectMeta,
+		Kind:       KindService,
+	}
+	if svc.Spec.ExternalName != "" {
+		src.Hosts = []string{svc.Spec.ExternalName}
+	}
+	for _, port := range svc.Spec.Ports {
+		protocol := string(port.Protocol)
+		if port.AppProtocol != nil && *port.AppProtocol != "" {
+			protocol = *port.AppProtocol
+		}
+		src.Ports = append(src.Ports, Port{
+			Name:     port.Name,
+			Number:   uint32(port.Port),
+			Protocol: protocol,
+		})
+	}
+	return src
+}
+
+// IsExternalName reports whether the Service is of type ExternalName.
+func IsExternalName(svc *corev1.Service) bool {
+	return svc.Spec.Type == corev1.ServiceTypeExternalName
+}
@@ -0,0 +1,59 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestExternalNameService(t *testing.T) {
+	cfg := getCfg()
+	logger := logr.Discard()
+	https := "HTTPS"
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "team-a"},
+		Spec: corev1.ServiceSpec{
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: "api.payments.example.com",
+			Ports: []corev1.ServicePort{
+				{Name: "https", Port: 443, Protocol: corev1.ProtocolTCP, AppProtocol: &https, TargetPort: intstr.FromInt32(8443)},
+				{Name: "smtp", Port: 25, Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+
+	src := FromService(svc)
+	wantPorts := []Port{
+		{Name: "https", Number: 443, Protocol: "HTTPS"},
+		{Name: "smtp", Number: 25, Protocol: "TCP"},
+	}
+	if diff := cmp.Diff(wantPorts, src.Ports); diff != "" {
+		t.Errorf("unexpected ports (-want +got):\n%s", diff)
+	}
+
+	sm, err := NewServiceMonitorMapper(&cfg, &logger).MapperForService(src, nil)
+	if err != nil {
+		t.Fatalf("MapperForService failed: '%v'", err)
+	}
+	if sm.Name != "buah-payments-service" || sm.Namespace != "team-a" {
+		t.Errorf("unexpected ServiceMonitor %s/%s", sm.Namespace, sm.Name)
+	}
+	if sm.Labels[ForLabel] != "payments" || sm.Labels[ForKindLabel] != KindService {
+		t.Errorf("unexpected labels %v", sm.Labels)
+	}
+	var got [][2]string
+	for _, e := range sm.Spec.Endpoints {
+		got = append(got, [2]string{e.Params["target"][0], e.Params["module"][0]})
+	}
+	want := [][2]string{
+		{"https://api.payments.example.com:443", "http_test"},
+		{"api.payments.example.com:25", "tcp_connect"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected targets (-want +got):\n%s", diff)
+	}
+}
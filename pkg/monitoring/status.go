@@ -0,0 +1,67 @@
+package monitoring
+
+import (
+	"encoding/json"
+)
+
+// StatusAnnotation holds the Status of the Source on the generated object as JSON.
+const StatusAnnotation = AnnotationPrefix + "status"
+
+// Status summarizes the last reconcile of a Source.
+type Status struct {
+	// Targets that are probed
+	Targets []TargetStatus `json:"targets,omitempty"`
+	// LastError of the last failed reconcile, empty if it succeeded
+	LastError string `json:"lastError,omitempty"`
+	// InvalidAnnotations of the Source that are ignored
+	InvalidAnnotations []string `json:"invalidAnnotations,omitempty"`
+}
+
+// TargetStatus is a probed target and the module it is probed with.
+type TargetStatus struct {
+	// Host of the Source the target is generated for
+	Host   string `json:"host"`
+	Target string `json:"target"`
+	Module string `json:"module"`
+	// Rule is the name of the module rule that selected the module, empty if none did
+	Rule string `json:"rule,omitempty"`
+	// HostMapping is the replacePattern of the applied host mapping, empty if none was
+	HostMapping string `json:"hostMapping,omitempty"`
+	// ModuleMapping is the matchPattern of the applied module mapping, empty if none was
+	ModuleMapping string `json:"moduleMapping,omitempty"`
+}
+
+// Summarize returns the Status of the targets of a Source.
+func Summarize(targets *Targets) Status {
+	status := Status{InvalidAnnotations: targets.invalidAnnotations}
+	for _, t := range targets.targets {
+		status.Targets = append(status.Targets, TargetStatus{
+			Host:          t.host,
+			Target:        t.target,
+			Module:        t.module,
+			Rule:          t.rule,
+			HostMapping:   t.hostMapping,
+			ModuleMapping: t.moduleMapping,
+		})
+	}
+	return status
+}
+
+// String returns the Status as value of StatusAnnotation.
+func (s Status) String() string {
+	out, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// ParseStatus returns the Status stored in the annotations, an empty Status if there is none
+// or it is invalid.
+func ParseStatus(annotations map[string]string) Status {
+	var status Status
+	if value, ok := annotations[StatusAnnotation]; ok {
+		_ = json.Unmarshal([]byte(value), &status)
+	}
+	return status
+}
@@ -0,0 +1,51 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSummarize(t *testing.T) {
+	cfg := &config.Config{
+		DefaultModule:  "http_2xx",
+		ModuleRules:    []config.ModuleRule{{Name: "https", Module: "http_tls", Protocols: []string{"HTTPS"}}},
+		HostMappings:   []config.HostMapping{{Port: 443, ReplacePattern: `^api\.`, ReplaceWith: "api-internal."}},
+		ModuleMappings: []config.ModuleMapping{{Port: 80, MatchPattern: `^api\.`, ReplaceModule: "http_api"}},
+	}
+	se := &istioNetworking.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{Name: "se", Namespace: "team-a", Annotations: map[string]string{
+			SkipPortsAnnotation: "9000",
+		}},
+		Spec: v1alpha3.ServiceEntry{
+			Hosts: []string{"api.example.com"},
+			Ports: []*v1alpha3.ServicePort{
+				{Name: "http", Number: 80, Protocol: "HTTP"},
+				{Name: "https", Number: 443, Protocol: "HTTPS"},
+				{Name: "admin", Number: 9000, Protocol: "HTTP"},
+			},
+		},
+	}
+	logger := logr.Discard()
+	got := Summarize(GenerateTargets(cfg, &logger, FromServiceEntry(se), nil))
+	want := Status{Targets: []TargetStatus{
+		{Host: "api.example.com", Target: "api.example.com:80", Module: "http_api", ModuleMapping: `^api\.`},
+		{Host: "api.example.com", Target: "https://api-internal.example.com:443", Module: "http_tls", Rule: "https", HostMapping: `^api\.`},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	got.LastError = "boom"
+	if parsed := ParseStatus(map[string]string{StatusAnnotation: got.String()}); !reflect.DeepEqual(parsed, got) {
+		t.Errorf("expected %+v after a round trip, got %+v", got, parsed)
+	}
+	if parsed := ParseStatus(map[string]string{StatusAnnotation: "{"}); !reflect.DeepEqual(parsed, Status{}) {
+		t.Errorf("expected an empty Status for an invalid annotation, got %+v", parsed)
+	}
+}
@@ -0,0 +1,107 @@
+package monitoring
+
+import (
+	"sort"
+	"strings"
+
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+)
+
+// TLSPorts maps the ports of a ServiceEntry whose traffic is TLS originated by a
+// DestinationRule to the upstream port, which is probed with https instead.
+type TLSPorts map[uint32]uint32
+
+// TLSOrigination returns the ports of the ServiceEntry for which a DestinationRule originates
+// TLS with mode SIMPLE or MUTUAL. Port level settings win over the TLS settings of the traffic
+// policy. Only DestinationRules visible in the namespace of the ServiceEntry are considered,
+// those of the same namespace and with an exact host take precedence. The upstream port is the
+// targetPort of the ServiceEntry port, its number if there is none.
+func TLSOrigination(se *istioNetworking.ServiceEntry, destinationRules []*istioNetworking.DestinationRule) TLSPorts {
+	var matching []*istioNetworking.DestinationRule
+	for _, dr := range destinationRules {
+		if DestinationRuleMatches(dr, se) {
+			matching = append(matching, dr)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		iLocal, jLocal := matching[i].Namespace == se.Namespace, matching[j].Namespace == se.Namespace
+		if iLocal != jLocal {
+			return iLocal
+		}
+		iWildcard, jWildcard := strings.HasPrefix(matching[i].Spec.Host, "*"), strings.HasPrefix(matching[j].Spec.Host, "*")
+		if iWildcard != jWildcard {
+			return jWildcard
+		}
+		return matching[i].Namespace+"/"+matching[i].Name < matching[j].Namespace+"/"+matching[j].Name
+	})
+
+	tlsPorts := TLSPorts{}
+	for _, port := range se.Spec.Ports {
+		for _, dr := range matching {
+			originated, ok := originatesTLS(dr.Spec.GetTrafficPolicy(), port.Number)
+			if !ok {
+				continue
+			}
+			if originated {
+				upstream := port.TargetPort
+				if upstream == 0 {
+					upstream = port.Number
+				}
+				tlsPorts[port.Number] = upstream
+			}
+			break
+		}
+	}
+	return tlsPorts
+}
+
+// DestinationRuleMatches reports whether the DestinationRule may originate TLS for the ServiceEntry.
+func DestinationRuleMatches(dr *istioNetworking.DestinationRule, se *istioNetworking.ServiceEntry) bool {
+	return isVisible(dr, se.Namespace) && matchesAnyHost(dr.Spec.Host, se.Spec.Hosts)
+}
+
+// originatesTLS reports whether the traffic policy originates TLS for the port. ok is false
+// if the policy has no TLS settings for the port.
+func originatesTLS(policy *v1alpha3.TrafficPolicy, port uint32) (originated bool, ok bool) {
+	if policy == nil {
+		return false, false
+	}
+	for _, settings := range policy.GetPortLevelSettings() {
+		if settings.GetPort().GetNumber() == port && settings.GetTls() != nil {
+			return isOriginating(settings.GetTls()), true
+		}
+	}
+	if policy.GetTls() != nil {
+		return isOriginating(policy.GetTls()), true
+	}
+	return false, false
+}
+
+func isOriginating(tls *v1alpha3.ClientTLSSettings) bool {
+	mode := tls.GetMode()
+	return mode == v1alpha3.ClientTLSSettings_SIMPLE || mode == v1alpha3.ClientTLSSettings_MUTUAL
+}
+
+// isVisible reports whether the DestinationRule is exported to the namespace.
+func isVisible(dr *istioNetworking.DestinationRule, namespace string) bool {
+	exportTo := dr.Spec.GetExportTo()
+	if len(exportTo) == 0 {
+		return true
+	}
+	for _, to := range exportTo {
+		if to == "*" || to == namespace || (to == "." && dr.Namespace == namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyHost(drHost string, hosts []string) bool {
+	for _, host := range hosts {
+		if drHost == host || (strings.HasPrefix(drHost, "*") && strings.HasSuffix(host, drHost[1:])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	"istio.io/api/networking/v1alpha3"
+	istioNetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func destinationRule(namespace, name, host string, policy *v1alpha3.TrafficPolicy, exportTo ...string) *istioNetworking.DestinationRule {
+	return &istioNetworking.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       v1alpha3.DestinationRule{Host: host, TrafficPolicy: policy, ExportTo: exportTo},
+	}
+}
+
+func portTLS(port uint32, mode v1alpha3.ClientTLSSettings_TLSmode) *v1alpha3.TrafficPolicy {
+	return &v1alpha3.TrafficPolicy{PortLevelSettings: []*v1alpha3.TrafficPolicy_PortTrafficPolicy{{
+		Port: &v1alpha3.PortSelector{Number: port},
+		Tls:  &v1alpha3.ClientTLSSettings{Mode: mode},
+	}}}
+}
+
+func TestTLSOrigination(t *testing.T) {
+	se := &istioNetworking.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{Name: "se", Namespace: "team-a"},
+		Spec: v1alpha3.ServiceEntry{
+			Hosts: []string{"api.example.com"},
+			Ports: []*v1alpha3.ServicePort{
+				{Name: "http", Number: 80, Protocol: "HTTP", TargetPort: 443},
+				{Name: "http-alt", Number: 8080, Protocol: "HTTP"},
+			},
+		},
+	}
+	tests := []struct {
+		name             string
+		destinationRules []*istioNetworking.DestinationRule
+		want             TLSPorts
+	}{
+		{name: "no DestinationRule", want: TLSPorts{}},
+		{
+			name:             "port level SIMPLE",
+			destinationRules: []*istioNetworking.DestinationRule{destinationRule("team-a", "dr", "api.example.com", portTLS(80, v1alpha3.ClientTLSSettings_SIMPLE))},
+			want:             TLSPorts{80: 443},
+		},
+		{
+			name: "traffic policy MUTUAL for all ports",
+			destinationRules: []*istioNetworking.DestinationRule{destinationRule("team-a", "dr", "*.example.com",
+				&v1alpha3.TrafficPolicy{Tls: &v1alpha3.ClientTLSSettings{Mode: v1alpha3.ClientTLSSettings_MUTUAL}})},
+			want: TLSPorts{80: 443, 8080: 8080},
+		},
+		{
+			name:             "ISTIO_MUTUAL is no TLS origination",
+			destinationRules: []*istioNetworking.DestinationRule{destinationRule("team-a", "dr", "api.example.com", portTLS(80, v1alpha3.ClientTLSSettings_ISTIO_MUTUAL))},
+			want:             TLSPorts{},
+		},
+		{
+			name:             "other host",
+			destinationRules: []*istioNetworking.DestinationRule{destinationRule("team-a", "dr", "www.example.com", portTLS(80, v1alpha3.ClientTLSSettings_SIMPLE))},
+			want:             TLSPorts{},
+		},
+		{
+			name:             "not exported to the namespace",
+			destinationRules: []*istioNetworking.DestinationRule{destinationRule("team-b", "dr", "api.example.com", portTLS(80, v1alpha3.ClientTLSSettings_SIMPLE), ".")},
+			want:             TLSPorts{},
+		},
+		{
+			name: "DestinationRule of the same namespace wins",
+			destinationRules: []*istioNetworking.DestinationRule{
+				destinationRule("team-b", "dr", "api.example.com", portTLS(80, v1alpha3.ClientTLSSettings_SIMPLE)),
+				destinationRule("team-a", "dr", "api.example.com", portTLS(80, v1alpha3.ClientTLSSettings_DISABLE)),
+			},
+			want: TLSPorts{},
+		},
+	}
+	for _, tt := range tests {
+		if got := TLSOrigination(se, tt.destinationRules); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	cfg := &config.Config{DefaultModule: "http_2xx"}
+	logger := logr.Discard()
+	targets, _ := generateTargets(cfg, &logger, FromServiceEntry(se), &Overrides{}, TLSPorts{80: 443})
+	if len(targets) != 2 || targets[0].target != "https://api.example.com:443" || targets[1].target != "api.example.com:8080" {
+		t.Errorf("expected an https target on the upstream port, got %+v", targets)
+	}
+}
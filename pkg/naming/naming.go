@@ -0,0 +1,107 @@
+package naming
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DefaultPattern is used when no naming pattern is configured.
+const DefaultPattern = "sm-%s"
+
+// MaxLength is the maximum length of a generated name. Names are also used as
+// label values, so they are limited to the length of a DNS-1123 label.
+const MaxLength = validation.DNS1123LabelMaxLength
+
+const hashLength = 8
+
+// Data is the input for a naming pattern.
+type Data struct {
+	// Name of the source object, e.g. the ServiceEntry
+	Name string
+	// Namespace of the source object
+	Namespace string
+	// Host is the first host of the source object
+	Host string
+	// Labels of the source object
+	Labels map[string]string
+	// Kind of the source object in lower case, empty for ServiceEntries. It is appended to
+	// the name, so objects generated for sources of different kinds do not collide.
+	Kind string
+}
+
+// Pattern renders names for generated objects.
+// It accepts either a printf style pattern with exactly one %s which is replaced
+// by the name of the source object, e.g. "sm-%s", or a Go template like
+// "{{ .Namespace }}-{{ .Name }}" which gets Data as input.
+type Pattern struct {
+	tmpl *template.Template
+}
+
+// Parse validates the pattern and returns a Pattern. An empty pattern falls back to DefaultPattern.
+func Parse(pattern string) (*Pattern, error) {
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	if !strings.Contains(pattern, "{{") {
+		if strings.Count(pattern, "%s") != 1 || strings.Count(pattern, "%") != 1 {
+			return nil, errors.New("naming pattern must contain exactly one %s or be a Go template")
+		}
+		pattern = strings.Replace(pattern, "%s", "{{ .Name }}", 1)
+	}
+	tmpl, err := template.New("name").Option("missingkey=zero").Parse(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid naming template: %w", err)
+	}
+	p := &Pattern{tmpl: tmpl}
+
+	// render once with sample data to catch unknown fields early. Labels are
+	// empty here, so the result itself is only validated in Execute.
+	_, err = p.render(Data{Name: "name", Namespace: "namespace", Host: "host.example.com", Labels: map[string]string{}})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pattern) render(data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("unable to render naming pattern: %w", err)
+	}
+	return strings.ToLower(strings.TrimSpace(buf.String())), nil
+}
+
+// Execute renders the name for the given data. Names longer than MaxLength are
+// truncated and suffixed with a hash of the full name to keep them unique.
+func (p *Pattern) Execute(data Data) (string, error) {
+	name, err := p.render(data)
+	if err != nil {
+		return "", err
+	}
+	if data.Kind != "" {
+		name += "-" + data.Kind
+	}
+	name = Truncate(name)
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("generated name %q is invalid: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// Truncate shortens names longer than MaxLength and appends a hash of the full name.
+func Truncate(name string) string {
+	if len(name) <= MaxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	prefix := strings.TrimRight(name[:MaxLength-hashLength-1], "-.")
+	return prefix + "-" + hex.EncodeToString(sum[:])[:hashLength]
+}
@@ -0,0 +1,107 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{name: "empty falls back to default", pattern: ""},
+		{name: "printf", pattern: "sm-%s"},
+		{name: "template", pattern: "{{ .Namespace }}-{{ .Name }}"},
+		{name: "no placeholder", pattern: "invalid", wantErr: true},
+		{name: "two placeholders", pattern: "%s-%s", wantErr: true},
+		{name: "other verb", pattern: "sm-%s-%d", wantErr: true},
+		{name: "broken template", pattern: "{{ .Name ", wantErr: true},
+		{name: "unknown field", pattern: "{{ .Unknown }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got '%v'", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	data := Data{
+		Name:      "external-service",
+		Namespace: "istio-system",
+		Host:      "www.ebay.de",
+		Labels:    map[string]string{"team": "Blue"},
+	}
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "", want: "sm-external-service"},
+		{pattern: "buah-%s", want: "buah-external-service"},
+		{pattern: "{{ .Namespace }}-{{ .Name }}", want: "istio-system-external-service"},
+		{pattern: "{{ .Host }}", want: "www.ebay.de"},
+		{pattern: `{{ index .Labels "team" }}-{{ .Name }}`, want: "blue-external-service"},
+		{pattern: `{{ index .Labels "missing" }}sm-{{ .Name }}`, want: "sm-external-service"},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.pattern)
+		if err != nil {
+			t.Fatalf("%q: unexpected error '%v'", tt.pattern, err)
+		}
+		got, err := p.Execute(data)
+		if err != nil {
+			t.Fatalf("%q: unexpected error '%v'", tt.pattern, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.pattern, tt.want, got)
+		}
+	}
+}
+
+func TestExecuteKind(t *testing.T) {
+	p, err := Parse("sm-%s")
+	if err != nil {
+		t.Fatalf("unexpected error '%v'", err)
+	}
+	got, err := p.Execute(Data{Name: "web", Kind: "ingress"})
+	if err != nil {
+		t.Fatalf("unexpected error '%v'", err)
+	}
+	if got != "sm-web-ingress" {
+		t.Errorf("expected sm-web-ingress, got %s", got)
+	}
+	got, err = p.Execute(Data{Name: strings.Repeat("a", 100), Kind: "ingress"})
+	if err != nil {
+		t.Fatalf("unexpected error '%v'", err)
+	}
+	if len(got) > MaxLength {
+		t.Errorf("expected at most %d characters, got %d", MaxLength, len(got))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := "sm-" + strings.Repeat("a", 100)
+	got := Truncate(long)
+	if len(got) > MaxLength {
+		t.Errorf("expected at most %d characters, got %d", MaxLength, len(got))
+	}
+	if got == Truncate(long+"b") {
+		t.Errorf("expected different names for different inputs, got %s", got)
+	}
+	if Truncate("sm-short") != "sm-short" {
+		t.Errorf("short names must not be modified")
+	}
+}
+
+func TestExecuteInvalidName(t *testing.T) {
+	p, err := Parse("sm_%s")
+	if err != nil {
+		t.Fatalf("unexpected error '%v'", err)
+	}
+	if _, err := p.Execute(Data{Name: "external-service"}); err == nil {
+		t.Errorf("expected error for invalid name")
+	}
+}
@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate checks the selector, durations and patterns of the ProbePolicy.
+func Validate(p *blackboxv1alpha1.ProbePolicy) field.ErrorList {
+	var errs field.ErrorList
+	spec := &p.Spec
+	specPath := field.NewPath("spec")
+
+	errs = append(errs, metav1validation.ValidateLabelSelector(&spec.Selector, metav1validation.LabelSelectorValidationOptions{}, specPath.Child("selector"))...)
+	interval, intervalErr := model.ParseDuration(spec.Interval)
+	if spec.Interval != "" && intervalErr != nil {
+		errs = append(errs, field.Invalid(specPath.Child("interval"), spec.Interval, intervalErr.Error()))
+	}
+	scrapeTimeout, scrapeTimeoutErr := model.ParseDuration(spec.ScrapeTimeout)
+	if spec.ScrapeTimeout != "" && scrapeTimeoutErr != nil {
+		errs = append(errs, field.Invalid(specPath.Child("scrapeTimeout"), spec.ScrapeTimeout, scrapeTimeoutErr.Error()))
+	}
+	if intervalErr == nil && scrapeTimeoutErr == nil && scrapeTimeout > interval {
+		errs = append(errs, field.Invalid(specPath.Child("scrapeTimeout"), spec.ScrapeTimeout, "must not be greater than interval "+spec.Interval))
+	}
+	for i, hm := range spec.HostMappings {
+		path := specPath.Child("hostMappings").Index(i)
+		errs = append(errs, validatePort(hm.Port, path.Child("port"))...)
+		if _, err := regexp.Compile(hm.ReplacePattern); err != nil {
+			errs = append(errs, field.Invalid(path.Child("replacePattern"), hm.ReplacePattern, err.Error()))
+		}
+		if hm.ReplaceWith == "" {
+			errs = append(errs, field.Required(path.Child("replaceWith"), ""))
+		}
+	}
+	for i, mm := range spec.ModuleMappings {
+		path := specPath.Child("moduleMappings").Index(i)
+		errs = append(errs, validatePort(mm.Port, path.Child("port"))...)
+		if _, err := regexp.Compile(mm.MatchPattern); err != nil {
+			errs = append(errs, field.Invalid(path.Child("matchPattern"), mm.MatchPattern, err.Error()))
+		}
+		if mm.ReplaceModule == "" {
+			errs = append(errs, field.Required(path.Child("replaceModule"), ""))
+		}
+	}
+	return errs
+}
+
+func validatePort(port int32, path *field.Path) field.ErrorList {
+	if port == 0 {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	if port < 0 || port > 65535 {
+		return field.ErrorList{field.Invalid(path, port, validation.InclusiveRangeError(1, 65535))}
+	}
+	return nil
+}
+
+// Matches reports whether the ProbePolicy applies to a ServiceEntry with the given namespace and labels.
+// An invalid policy matches nothing.
+func Matches(p *blackboxv1alpha1.ProbePolicy, namespace string, seLabels map[string]string) bool {
+	if p.Namespace != namespace || len(Validate(p)) > 0 {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&p.Spec.Selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(seLabels))
+}
+
+// Select returns the policies that apply to a ServiceEntry ordered by precedence, highest first:
+// higher priorities win, on equal priority the policy whose name sorts first wins.
+func Select(policies []blackboxv1alpha1.ProbePolicy, namespace string, seLabels map[string]string) []*blackboxv1alpha1.ProbePolicy {
+	var selected []*blackboxv1alpha1.ProbePolicy
+	for i := range policies {
+		if Matches(&policies[i], namespace, seLabels) {
+			selected = append(selected, &policies[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Spec.Priority != selected[j].Spec.Priority {
+			return selected[i].Spec.Priority > selected[j].Spec.Priority
+		}
+		return selected[i].Name < selected[j].Name
+	})
+	return selected
+}
+
+// Apply merges the policies, ordered as returned by Select, over cfg and returns the result.
+// cfg is not modified. Values of a policy with higher precedence override the ones of policies
+// with lower precedence and of cfg, its mappings are evaluated first. Like for the interval
+// annotation, a scrapeTimeout of cfg greater than the interval of a policy is capped at the
+// interval. The result is validated like a config file, e.g. a scrapeTimeout of one policy must
+// not exceed the interval of another.
+func Apply(cfg *config.Config, policies []*blackboxv1alpha1.ProbePolicy) (*config.Config, error) {
+	if len(policies) == 0 {
+		return cfg, nil
+	}
+	merged := *cfg
+	// validation compiles the patterns into the elements, cfg is shared
+	merged.HostMappings = append([]config.HostMapping(nil), cfg.HostMappings...)
+	merged.ModuleMappings = append([]config.ModuleMapping(nil), cfg.ModuleMappings...)
+	merged.ModuleRules = append([]config.ModuleRule(nil), cfg.ModuleRules...)
+	merged.Alerts.Templates = append([]config.AlertTemplate(nil), cfg.Alerts.Templates...)
+	merged.ProtocolModuleMappings = make(map[string]string, len(cfg.ProtocolModuleMappings))
+	for k, v := range cfg.ProtocolModuleMappings {
+		merged.ProtocolModuleMappings[k] = v
+	}
+
+	// lowest precedence first, so the policy with the highest precedence is merged last
+	scrapeTimeoutSet := false
+	for i := len(policies) - 1; i >= 0; i-- {
+		spec := &policies[i].Spec
+		if spec.DefaultModule != "" {
+			merged.DefaultModule = spec.DefaultModule
+		}
+		if spec.Interval != "" {
+			merged.Interval = monitoringv1.Duration(spec.Interval)
+		}
+		if spec.ScrapeTimeout != "" {
+			merged.ScrapeTimeout = monitoringv1.Duration(spec.ScrapeTimeout)
+			scrapeTimeoutSet = true
+		}
+		hostMappings := make([]config.HostMapping, 0, len(spec.HostMappings)+len(merged.HostMappings))
+		for _, hm := range spec.HostMappings {
+			hostMappings = append(hostMappings, config.HostMapping{
+				Port:           uint32(hm.Port),
+				ReplacePattern: hm.ReplacePattern,
+				ReplaceWith:    hm.ReplaceWith,
+				Scheme:         hm.Scheme,
+				TargetPort:     uint32(hm.TargetPort),
+				Path:           hm.Path,
+			})
+		}
+		merged.HostMappings = append(hostMappings, merged.HostMappings...)
+		moduleMappings := make([]config.ModuleMapping, 0, len(spec.ModuleMappings)+len(merged.ModuleMappings))
+		for _, mm := range spec.ModuleMappings {
+			moduleMappings = append(moduleMappings, config.ModuleMapping{
+				Port:          uint32(mm.Port),
+				MatchPattern:  mm.MatchPattern,
+				ReplaceModule: mm.ReplaceModule,
+			})
+		}
+		merged.ModuleMappings = append(moduleMappings, merged.ModuleMappings...)
+		for k, v := range spec.ProtocolModuleMappings {
+			merged.ProtocolModuleMappings[k] = v
+		}
+	}
+	if !scrapeTimeoutSet {
+		interval, intervalErr := model.ParseDuration(string(merged.Interval))
+		scrapeTimeout, scrapeTimeoutErr := model.ParseDuration(string(merged.ScrapeTimeout))
+		if intervalErr == nil && scrapeTimeoutErr == nil && scrapeTimeout > interval {
+			merged.ScrapeTimeout = merged.Interval
+		}
+	}
+	if err := merged.Validate(); err != nil {
+		return nil, fmt.Errorf("policies %s: %w", strings.Join(Names(policies), ", "), err)
+	}
+	return &merged, nil
+}
+
+// Names returns the names of the policies.
+func Names(policies []*blackboxv1alpha1.ProbePolicy) []string {
+	names := make([]string, 0, len(policies))
+	for _, p := range policies {
+		names = append(names, p.Name)
+	}
+	return names
+}
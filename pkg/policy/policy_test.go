@@ -0,0 +1,257 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	blackboxv1alpha1 "github.com/schmiddim/blackbox-operator/api/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newPolicy(name, namespace string, priority int32, selector map[string]string, spec blackboxv1alpha1.ProbePolicySpec) blackboxv1alpha1.ProbePolicy {
+	spec.Priority = priority
+	spec.Selector = metav1.LabelSelector{MatchLabels: selector}
+	return blackboxv1alpha1.ProbePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       spec,
+	}
+}
+
+func TestSelect(t *testing.T) {
+	policies := []blackboxv1alpha1.ProbePolicy{
+		newPolicy("b", "team-a", 0, nil, blackboxv1alpha1.ProbePolicySpec{}),
+		newPolicy("a", "team-a", 0, nil, blackboxv1alpha1.ProbePolicySpec{}),
+		newPolicy("low", "team-a", -1, nil, blackboxv1alpha1.ProbePolicySpec{}),
+		newPolicy("high", "team-a", 10, map[string]string{"tier": "critical"}, blackboxv1alpha1.ProbePolicySpec{}),
+		newPolicy("other-namespace", "team-b", 100, nil, blackboxv1alpha1.ProbePolicySpec{}),
+		newPolicy("invalid-pattern", "team-a", 0, nil, blackboxv1alpha1.ProbePolicySpec{
+			HostMappings: []blackboxv1alpha1.HostMapping{{Port: 443, ReplacePattern: "(", ReplaceWith: "b"}},
+		}),
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "team-a"},
+			Spec: blackboxv1alpha1.ProbePolicySpec{Selector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Unknown"}},
+			}},
+		},
+	}
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{name: "without labels", labels: nil, want: []string{"a", "b", "low"}},
+		{name: "critical", labels: map[string]string{"tier": "critical"}, want: []string{"high", "a", "b", "low"}},
+	}
+	for _, tt := range tests {
+		got := Names(Select(policies, "team-a", tt.labels))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg, err := config.ParseConfig([]byte(`
+defaultModule: http_2xx
+interval: 30s
+scrapeTimeout: 10s
+hostMappings:
+  - port: 443
+    replacePattern: global.
+    replaceWith: global.*/health
+protocolModuleMappings:
+  TCP: tcp_connect
+  HTTPS: http_2xx
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := []blackboxv1alpha1.ProbePolicy{
+		newPolicy("high", "team-a", 10, nil, blackboxv1alpha1.ProbePolicySpec{
+			Interval: "1m",
+			HostMappings: []blackboxv1alpha1.HostMapping{
+				{Port: 443, ReplacePattern: "high.", ReplaceWith: "high.*/health"},
+			},
+		}),
+		newPolicy("low", "team-a", 0, nil, blackboxv1alpha1.ProbePolicySpec{
+			Interval:      "5m",
+			DefaultModule: "http_3xx",
+			HostMappings: []blackboxv1alpha1.HostMapping{
+				{Port: 443, ReplacePattern: "low.", ReplaceWith: "low.*/health"},
+			},
+			ModuleMappings: []blackboxv1alpha1.ModuleMapping{
+				{Port: 443, MatchPattern: "low.", ReplaceModule: "tcp_connect"},
+			},
+			ProtocolModuleMappings: map[string]string{"HTTPS": "http_tls"},
+		}),
+	}
+
+	merged, err := Apply(cfg, Select(policies, "team-a", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.Interval != "1m" {
+		t.Errorf("expected interval of the policy with the highest priority, got %s", merged.Interval)
+	}
+	if merged.ScrapeTimeout != "10s" {
+		t.Errorf("expected scrapeTimeout of the global config, got %s", merged.ScrapeTimeout)
+	}
+	if merged.DefaultModule != "http_3xx" {
+		t.Errorf("expected defaultModule of the low priority policy, got %s", merged.DefaultModule)
+	}
+	wantPatterns := []string{"high.", "low.", "global."}
+	var gotPatterns []string
+	for _, hm := range merged.HostMappings {
+		gotPatterns = append(gotPatterns, hm.ReplacePattern)
+	}
+	if !reflect.DeepEqual(gotPatterns, wantPatterns) {
+		t.Errorf("expected host mappings %v, got %v", wantPatterns, gotPatterns)
+	}
+	if len(merged.ModuleMappings) != 1 || merged.ModuleMappings[0].ReplaceModule != "tcp_connect" {
+		t.Errorf("expected module mapping of the low priority policy, got %v", merged.ModuleMappings)
+	}
+	wantProtocols := map[string]string{"TCP": "tcp_connect", "HTTPS": "http_tls"}
+	if !reflect.DeepEqual(merged.ProtocolModuleMappings, wantProtocols) {
+		t.Errorf("expected protocol module mappings %v, got %v", wantProtocols, merged.ProtocolModuleMappings)
+	}
+
+	// the global config is not modified
+	if cfg.Interval != "30s" || len(cfg.HostMappings) != 1 || cfg.ProtocolModuleMappings["HTTPS"] != "http_2xx" {
+		t.Errorf("global config was modified: %v", cfg)
+	}
+	if unchanged, err := Apply(cfg, nil); err != nil || unchanged != cfg {
+		t.Errorf("expected the global config without policies, got %v", err)
+	}
+}
+
+func TestApplyCapsScrapeTimeout(t *testing.T) {
+	cfg, err := config.ParseConfig([]byte(`
+interval: 30s
+scrapeTimeout: 10s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := []blackboxv1alpha1.ProbePolicy{
+		newPolicy("fast", "team-a", 0, nil, blackboxv1alpha1.ProbePolicySpec{Interval: "5s"}),
+	}
+	merged, err := Apply(cfg, Select(policies, "team-a", nil))
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if merged.Interval != "5s" || merged.ScrapeTimeout != "5s" {
+		t.Errorf("expected the global scrapeTimeout capped at the interval 5s, got %s and %s", merged.Interval, merged.ScrapeTimeout)
+	}
+	if cfg.ScrapeTimeout != "10s" {
+		t.Errorf("global config was modified: %v", cfg)
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	cfg, err := config.ParseConfig([]byte(`
+interval: 30s
+scrapeTimeout: 10s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		policies []blackboxv1alpha1.ProbePolicy
+		want     string
+	}{
+		{
+			name: "scrapeTimeout of one policy above the interval of another",
+			policies: []blackboxv1alpha1.ProbePolicy{
+				newPolicy("interval", "team-a", 10, nil, blackboxv1alpha1.ProbePolicySpec{Interval: "15s"}),
+				newPolicy("timeout", "team-a", 0, nil, blackboxv1alpha1.ProbePolicySpec{ScrapeTimeout: "20s"}),
+			},
+			want: "policies interval, timeout: invalid config",
+		},
+	}
+	for _, tt := range tests {
+		_, err := Apply(cfg, Select(tt.policies, "team-a", nil))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestApplyUndeclaredModule(t *testing.T) {
+	cfg, err := config.ParseConfig([]byte(`
+defaultModule: http_2xx
+exporter:
+  configMap:
+    name: blackbox-exporter-config
+    namespace: monitoring
+  modules:
+    http_2xx:
+      prober: http
+    tcp_connect:
+      prober: tcp
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		spec blackboxv1alpha1.ProbePolicySpec
+		want string
+	}{
+		{name: "declared modules", spec: blackboxv1alpha1.ProbePolicySpec{
+			DefaultModule:  "tcp_connect",
+			ModuleMappings: []blackboxv1alpha1.ModuleMapping{{Port: 443, MatchPattern: "a", ReplaceModule: "http_2xx"}},
+		}},
+		{name: "undeclared defaultModule", spec: blackboxv1alpha1.ProbePolicySpec{DefaultModule: "http_2xxx"},
+			want: `defaultModule: Not found: "http_2xxx"`},
+		{name: "undeclared module of a module mapping", spec: blackboxv1alpha1.ProbePolicySpec{
+			ModuleMappings: []blackboxv1alpha1.ModuleMapping{{Port: 443, MatchPattern: "a", ReplaceModule: "tls_connect"}},
+		}, want: `moduleMappings[0].replaceModule: Not found: "tls_connect"`},
+	}
+	for _, tt := range tests {
+		policies := []blackboxv1alpha1.ProbePolicy{newPolicy("p", "team-a", 0, nil, tt.spec)}
+		_, err := Apply(cfg, Select(policies, "team-a", nil))
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec blackboxv1alpha1.ProbePolicySpec
+		want []string
+	}{
+		{name: "valid", spec: blackboxv1alpha1.ProbePolicySpec{
+			Interval: "1m", ScrapeTimeout: "10s",
+			HostMappings:   []blackboxv1alpha1.HostMapping{{Port: 443, ReplacePattern: "a", ReplaceWith: "b"}},
+			ModuleMappings: []blackboxv1alpha1.ModuleMapping{{Port: 443, MatchPattern: "a", ReplaceModule: "tcp_connect"}},
+		}},
+		{name: "scrapeTimeout greater than interval", spec: blackboxv1alpha1.ProbePolicySpec{Interval: "10s", ScrapeTimeout: "1m"},
+			want: []string{"spec.scrapeTimeout"}},
+		{name: "missing fields of the mappings", spec: blackboxv1alpha1.ProbePolicySpec{
+			HostMappings:   []blackboxv1alpha1.HostMapping{{ReplacePattern: "a"}},
+			ModuleMappings: []blackboxv1alpha1.ModuleMapping{{Port: 70000, MatchPattern: "a"}},
+		}, want: []string{
+			"spec.hostMappings[0].port", "spec.hostMappings[0].replaceWith",
+			"spec.moduleMappings[0].port", "spec.moduleMappings[0].replaceModule",
+		}},
+	}
+	for _, tt := range tests {
+		p := newPolicy("p", "team-a", 0, nil, tt.spec)
+		var got []string
+		for _, err := range Validate(&p) {
+			got = append(got, err.Field)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected errors for %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
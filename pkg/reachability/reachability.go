@@ -0,0 +1,131 @@
+package reachability
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+)
+
+const (
+	// AnnotationPrefix is the prefix of the annotations written to a Source. It differs from
+	// monitoring.AnnotationPrefix, those annotations are read by the operator.
+	AnnotationPrefix = "reachability.blackbox.schmiddim.io/"
+	// StateAnnotation holds the State of the Source.
+	StateAnnotation = AnnotationPrefix + "state"
+	// LastChangeAnnotation holds the time the State last changed in RFC 3339.
+	LastChangeAnnotation = AnnotationPrefix + "last-change"
+	// FailingHostsAnnotation is a comma separated list of the hosts with a failing target.
+	FailingHostsAnnotation = AnnotationPrefix + "failing-hosts"
+)
+
+// State is the aggregated probe result of a Source.
+type State string
+
+const (
+	// Reachable means every target is probed successfully.
+	Reachable State = "reachable"
+	// Unreachable means at least one target fails.
+	Unreachable State = "unreachable"
+	// Unknown means there is no result for at least one target and none fails.
+	Unknown State = "unknown"
+)
+
+// Result is the aggregated probe result of a Source.
+type Result struct {
+	State State
+	// FailingHosts are the sorted hosts with a failing target
+	FailingHosts []string
+}
+
+// Querier returns the probe results of the targets generated for a Source.
+type Querier interface {
+	// ProbeSuccess returns probe_success by target for the Source of the kind, namespace and
+	// name. A target with several series, e.g. of several blackbox exporters, fails if any of
+	// them fails.
+	ProbeSuccess(ctx context.Context, kind, namespace, name string) (map[string]float64, error)
+}
+
+// PrometheusQuerier queries the Prometheus HTTP API.
+type PrometheusQuerier struct {
+	API promv1.API
+}
+
+// NewPrometheusQuerier returns a Querier for the Prometheus HTTP API at address.
+func NewPrometheusQuerier(address string) (*PrometheusQuerier, error) {
+	c, err := api.NewClient(api.Config{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	return &PrometheusQuerier{API: promv1.NewAPI(c)}, nil
+}
+
+// ProbeSuccess implements Querier. The instance label of the series is the target.
+func (q *PrometheusQuerier) ProbeSuccess(ctx context.Context, kind, namespace, name string) (map[string]float64, error) {
+	query := fmt.Sprintf("probe_success{%s}", monitoring.SourceSelector(kind, namespace, name))
+	value, _, err := q.API.Query(ctx, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("query %s returned %s instead of a vector", query, value.Type())
+	}
+	success := map[string]float64{}
+	for _, sample := range vector {
+		target := string(sample.Metric[model.InstanceLabel])
+		if previous, ok := success[target]; ok && previous < float64(sample.Value) {
+			continue
+		}
+		success[target] = float64(sample.Value)
+	}
+	return success, nil
+}
+
+// Aggregate returns the Result of the targets. Results of other targets, e.g. of a Source with
+// the same name in another namespace, are ignored.
+func Aggregate(targets []monitoring.TargetStatus, success map[string]float64) Result {
+	result := Result{State: Reachable}
+	if len(targets) == 0 {
+		result.State = Unknown
+	}
+	for _, t := range targets {
+		value, ok := success[t.Target]
+		switch {
+		case !ok:
+			if result.State == Reachable {
+				result.State = Unknown
+			}
+		case value < 1:
+			result.State = Unreachable
+			if !slices.Contains(result.FailingHosts, t.Host) {
+				result.FailingHosts = append(result.FailingHosts, t.Host)
+			}
+		}
+	}
+	sort.Strings(result.FailingHosts)
+	return result
+}
+
+// Annotations returns the annotations of the Result. The last change is taken from current
+// unless the State differs from the one in current.
+func Annotations(current map[string]string, result Result, now time.Time) map[string]string {
+	annotations := map[string]string{
+		StateAnnotation:      string(result.State),
+		LastChangeAnnotation: current[LastChangeAnnotation],
+	}
+	if current[StateAnnotation] != string(result.State) || annotations[LastChangeAnnotation] == "" {
+		annotations[LastChangeAnnotation] = now.UTC().Format(time.RFC3339)
+	}
+	if len(result.FailingHosts) > 0 {
+		annotations[FailingHostsAnnotation] = strings.Join(result.FailingHosts, ",")
+	}
+	return annotations
+}
@@ -0,0 +1,132 @@
+package reachability
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schmiddim/blackbox-operator/pkg/monitoring"
+)
+
+// fakePrometheus answers instant queries with the body, it records the last query.
+func fakePrometheus(t *testing.T, body string, query *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("unable to parse the query: %v", err)
+		}
+		*query = r.Form.Get("query")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, body)
+	}))
+}
+
+func TestPrometheusQuerier(t *testing.T) {
+	var query string
+	server := fakePrometheus(t, `{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"instance":"https://api.example.com:443","for":"se"},"value":[1700000000,"1"]},
+		{"metric":{"instance":"https://www.example.com:443","for":"se","pod":"a"},"value":[1700000000,"1"]},
+		{"metric":{"instance":"https://www.example.com:443","for":"se","pod":"b"},"value":[1700000000,"0"]}
+	]}}`, &query)
+	defer server.Close()
+
+	querier, err := NewPrometheusQuerier(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := querier.ProbeSuccess(context.Background(), "ServiceEntry", "team-a", "se")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != `probe_success{for="se",for_namespace="team-a",for_kind="ServiceEntry"}` {
+		t.Errorf("unexpected query %s", query)
+	}
+	want := map[string]float64{"https://api.example.com:443": 1, "https://www.example.com:443": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	failing := fakePrometheus(t, `{"status":"error","errorType":"bad_data","error":"parse error"}`, &query)
+	defer failing.Close()
+	querier, err = NewPrometheusQuerier(failing.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := querier.ProbeSuccess(context.Background(), "ServiceEntry", "team-a", "se"); err == nil {
+		t.Error("expected an error of the API")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	targets := []monitoring.TargetStatus{
+		{Host: "api.example.com", Target: "api.example.com:80"},
+		{Host: "api.example.com", Target: "https://api.example.com:443"},
+		{Host: "www.example.com", Target: "https://www.example.com:443"},
+	}
+	tests := []struct {
+		name    string
+		targets []monitoring.TargetStatus
+		success map[string]float64
+		want    Result
+	}{
+		{
+			name:    "all targets succeed",
+			targets: targets,
+			success: map[string]float64{"api.example.com:80": 1, "https://api.example.com:443": 1, "https://www.example.com:443": 1, "other:80": 0},
+			want:    Result{State: Reachable},
+		},
+		{
+			name:    "failing targets",
+			targets: targets,
+			success: map[string]float64{"api.example.com:80": 0, "https://api.example.com:443": 0, "https://www.example.com:443": 1},
+			want:    Result{State: Unreachable, FailingHosts: []string{"api.example.com"}},
+		},
+		{
+			name:    "missing and failing targets",
+			targets: targets,
+			success: map[string]float64{"https://www.example.com:443": 0},
+			want:    Result{State: Unreachable, FailingHosts: []string{"www.example.com"}},
+		},
+		{
+			name:    "missing targets",
+			targets: targets,
+			success: map[string]float64{"api.example.com:80": 1},
+			want:    Result{State: Unknown},
+		},
+		{name: "no targets", want: Result{State: Unknown}},
+	}
+	for _, tt := range tests {
+		if got := Aggregate(tt.targets, tt.success); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := "2024-04-30T08:00:00Z"
+
+	got := Annotations(map[string]string{StateAnnotation: "reachable", LastChangeAnnotation: earlier}, Result{State: Reachable}, now)
+	want := map[string]string{StateAnnotation: "reachable", LastChangeAnnotation: earlier}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unchanged state: expected %v, got %v", want, got)
+	}
+
+	got = Annotations(map[string]string{StateAnnotation: "reachable", LastChangeAnnotation: earlier},
+		Result{State: Unreachable, FailingHosts: []string{"a.example.com", "b.example.com"}}, now)
+	want = map[string]string{
+		StateAnnotation:        "unreachable",
+		LastChangeAnnotation:   "2024-05-01T12:00:00Z",
+		FailingHostsAnnotation: "a.example.com,b.example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed state: expected %v, got %v", want, got)
+	}
+}
@@ -1,64 +1,33 @@
 package utils
 
 import (
-	"encoding/json"
-	"fmt"
 	"github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
-	"istio.io/client-go/pkg/apis/networking/v1alpha3"
-	"os"
-	"sigs.k8s.io/yaml/goyaml.v3"
+	"github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/schmiddim/blackbox-operator/pkg/manifest"
+	networkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 )
 
-func LoadServiceEntry(filename string) (*v1alpha3.ServiceEntry, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		fmt.Println("Error reading YAML file:", err)
-		return nil, err
-	}
-	var jsonData interface{}
-	if err := yaml.Unmarshal(data, &jsonData); err != nil {
-		fmt.Println("Error unmarshalling YAML:", err)
-		return nil, err
-	}
-	result, err := json.MarshalIndent(jsonData, "", "  ")
-	if err != nil {
-		fmt.Println("Error marshalling to JSON:", err)
-		return nil, err
-	}
-
-	seJson := v1alpha3.ServiceEntry{}
-	err = json.Unmarshal(result, &seJson)
-
-	if err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
-	}
-
-	return &seJson, err
+func LoadServiceEntry(filename string) (*networkingv1alpha3.ServiceEntry, error) {
+	se := &networkingv1alpha3.ServiceEntry{}
+	return se, manifest.Load(filename, se)
 }
 
 func LoadServiceMonitor(filename string) (*v1.ServiceMonitor, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		fmt.Println("Error reading YAML file:", err)
-		return nil, err
-	}
-	var jsonData interface{}
-	if err := yaml.Unmarshal(data, &jsonData); err != nil {
-		fmt.Println("Error unmarshalling YAML:", err)
-		return nil, err
-	}
-	result, err := json.MarshalIndent(jsonData, "", "  ")
-	if err != nil {
-		fmt.Println("Error marshalling to JSON:", err)
-		return nil, err
-	}
+	sm := &v1.ServiceMonitor{}
+	return sm, manifest.Load(filename, sm)
+}
 
-	smJson := v1.ServiceMonitor{}
-	err = json.Unmarshal(result, &smJson)
+func LoadProbe(filename string) (*v1.Probe, error) {
+	probe := &v1.Probe{}
+	return probe, manifest.Load(filename, probe)
+}
 
-	if err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
-	}
-	return &smJson, err
+func LoadScrapeConfig(filename string) (*v1alpha1.ScrapeConfig, error) {
+	sc := &v1alpha1.ScrapeConfig{}
+	return sc, manifest.Load(filename, sc)
+}
 
+func LoadPrometheusRule(filename string) (*v1.PrometheusRule, error) {
+	rule := &v1.PrometheusRule{}
+	return rule, manifest.Load(filename, rule)
 }